@@ -0,0 +1,270 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kataras/iris/v12"
+
+	"github.com/atcharles/glibs/giris"
+	"github.com/atcharles/glibs/j2rpc"
+)
+
+// defaultBufferSize the records waiting to be written by the sinks
+const defaultBufferSize = 1024
+
+type (
+	// Record is the audit entry of an RPC call
+	Record struct {
+		Time       time.Time       `json:"time"`
+		Method     string          `json:"method"`
+		User       string          `json:"user,omitempty"`
+		Params     json.RawMessage `json:"params,omitempty"`
+		Duration   time.Duration   `json:"duration"`
+		ErrorCode  int             `json:"error_code,omitempty"`
+		Error      string          `json:"error,omitempty"`
+		RemoteAddr string          `json:"remote_addr,omitempty"`
+		RequestID  string          `json:"request_id,omitempty"`
+	}
+
+	// Sink stores the records
+	Sink interface {
+		Write(r *Record) error
+		Close() error
+	}
+
+	// ItfAuditUser is implemented by the user stored in the iris context to name itself in the records
+	ItfAuditUser interface {
+		AuditUser() string
+	}
+
+	// UserResolver returns the user of the call
+	UserResolver func(c *j2rpc.Context) string
+)
+
+// Auditor writes a record of each RPC call to the sinks, the sinks run in their own goroutine
+type Auditor struct {
+	mu           sync.RWMutex
+	sinks        []Sink
+	userResolver UserResolver
+	//enabled of namespaces or methods (namespace.method), others use defaultOn
+	enabled   map[string]bool
+	defaultOn bool
+
+	records chan *Record
+	//dropped the records dropped while the buffer is full
+	dropped atomic.Uint64
+	stop    chan struct{}
+	done    chan struct{}
+	closed  atomic.Bool
+	logger  j2rpc.LevelLogger
+}
+
+// Attach writes the records of the calls of s
+func (a *Auditor) Attach(s j2rpc.RPCServer) *Auditor {
+	s.OnComplete(a.Handle)
+	return a
+}
+
+// Close stops receiving records, waits for the pending ones and closes the sinks
+func (a *Auditor) Close() (err error) {
+	if !a.closed.CompareAndSwap(false, true) {
+		return
+	}
+	close(a.stop)
+	<-a.done
+	for _, sink := range a.sinks {
+		if e := sink.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return
+}
+
+// Dropped returns the number of the records dropped because the sinks were too slow
+func (a *Auditor) Dropped() uint64 { return a.dropped.Load() }
+
+// Disable stops auditing the namespaces or methods (namespace.method), no names disables all
+func (a *Auditor) Disable(names ...string) *Auditor { return a.set(false, names) }
+
+// Enable audits the namespaces or methods (namespace.method), no names enables all
+func (a *Auditor) Enable(names ...string) *Auditor { return a.set(true, names) }
+
+// Enabled reports whether the method is audited, the method setting takes precedence over its namespace
+func (a *Auditor) Enabled(method string) bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if on, ok := a.enabled[method]; ok {
+		return on
+	}
+	if on, ok := a.enabled[strings.SplitN(method, ".", 2)[0]]; ok {
+		return on
+	}
+	return a.defaultOn
+}
+
+// Handle is the j2rpc.CompleteHandler of the auditor
+func (a *Auditor) Handle(c *j2rpc.Context) {
+	msg := c.Msg()
+	if len(msg.Method) == 0 || !a.Enabled(msg.Method) {
+		return
+	}
+	r := &Record{
+		Time:   time.Now(),
+		Method: msg.Method,
+	}
+	if _val, ok := c.Store.Load(j2rpc.StartTimeKey); ok {
+		r.Time = time.Unix(0, _val.(int64))
+	}
+	if _val, ok := c.Store.Load(j2rpc.TimeUsedKey); ok {
+		r.Duration = time.Duration(_val.(int64))
+	}
+	if msg.Error != nil {
+		r.ErrorCode, r.Error = int(msg.Error.Code), msg.Error.Message
+	}
+	if args := c.Args(); len(args) > 0 {
+		params, err := json.Marshal(RedactArgs(args))
+		if err != nil {
+			a.logger.Errorf("[Audit] %s: %s", msg.Method, err.Error())
+		}
+		r.Params = params
+	}
+	a.mu.RLock()
+	resolver := a.userResolver
+	a.mu.RUnlock()
+	r.User = resolver(c)
+	if req := c.Request(); req != nil {
+		r.RemoteAddr = req.RemoteAddr
+		r.RequestID = req.Header.Get("X-Request-Id")
+	}
+	if ic, ok := c.Context.(iris.Context); ok {
+		r.RemoteAddr = ic.RemoteAddr()
+	}
+	if w := c.Writer(); w != nil && len(w.Header().Get("request-id")) > 0 {
+		r.RequestID = w.Header().Get("request-id")
+	}
+	if a.closed.Load() {
+		return
+	}
+	// never stall the call on the sinks, the record is dropped when the buffer is full
+	select {
+	case a.records <- r:
+	default:
+		if n := a.dropped.Add(1); n&(n-1) == 0 {
+			a.logger.Warnf("[Audit] the buffer is full, %d records dropped", n)
+		}
+	}
+}
+
+// SetLogger sets the logger of the sink errors
+func (a *Auditor) SetLogger(logger j2rpc.LevelLogger) *Auditor {
+	a.logger = logger
+	return a
+}
+
+// SetUserResolver ...
+func (a *Auditor) SetUserResolver(resolver UserResolver) *Auditor {
+	a.mu.Lock()
+	a.userResolver = resolver
+	a.mu.Unlock()
+	return a
+}
+
+// run writes the records until the auditor is closed, then the buffered ones
+func (a *Auditor) run() {
+	defer close(a.done)
+	for {
+		select {
+		case r := <-a.records:
+			a.write(r)
+		case <-a.stop:
+			for {
+				select {
+				case r := <-a.records:
+					a.write(r)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// set ...
+func (a *Auditor) set(on bool, names []string) *Auditor {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(names) == 0 {
+		a.enabled = make(map[string]bool)
+		a.defaultOn = on
+		return a
+	}
+	for _, name := range names {
+		a.enabled[name] = on
+	}
+	return a
+}
+
+// write writes the record to the sinks
+func (a *Auditor) write(r *Record) {
+	for _, sink := range a.sinks {
+		if err := sink.Write(r); err != nil {
+			a.logger.Errorf("[Audit] %T write error: %s", sink, err.Error())
+		}
+	}
+}
+
+// IrisUser resolves the user set by giris.JWT in the iris context,
+// by ItfAuditUser, a string, or the ID field of the user
+func IrisUser(c *j2rpc.Context) string {
+	ic, ok := c.Context.(iris.Context)
+	if !ok {
+		return ""
+	}
+	user := ic.Values().Get(giris.ContextUser)
+	switch v := user.(type) {
+	case nil:
+		return ""
+	case ItfAuditUser:
+		return v.AuditUser()
+	case string:
+		return v
+	case fmt.Stringer:
+		return v.String()
+	}
+	rv := reflect.ValueOf(user)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return ""
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return ""
+	}
+	if id := rv.FieldByName("ID"); id.IsValid() && id.CanInterface() {
+		return fmt.Sprint(id.Interface())
+	}
+	return ""
+}
+
+// New returns an auditor writing to the sinks, all namespaces are audited
+func New(sinks ...Sink) *Auditor {
+	a := &Auditor{
+		sinks:        sinks,
+		userResolver: IrisUser,
+		enabled:      make(map[string]bool),
+		defaultOn:    true,
+		records:      make(chan *Record, defaultBufferSize),
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+		logger:       j2rpc.NewLevelLogger("[AUDIT]"),
+	}
+	go a.run()
+	return a
+}
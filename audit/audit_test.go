@@ -0,0 +1,140 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/atcharles/glibs/j2rpc"
+)
+
+type (
+	auditService struct{}
+
+	auditLogin struct {
+		Username string `json:"username"`
+		Password string `json:"password" audit:"redact"`
+		Token    string `json:"token" audit:"-"`
+	}
+
+	// memSink keeps the records, Write waits for block when it's not nil
+	memSink struct {
+		mu      sync.Mutex
+		records []*Record
+		block   chan struct{}
+	}
+)
+
+func (auditService) Login(_ context.Context, p auditLogin) error {
+	if p.Password != "secret" {
+		return j2rpc.NewError(j2rpc.ErrForbidden, "denied")
+	}
+	return nil
+}
+
+func (auditService) Ping(_ context.Context) (string, error) { return "pong", nil }
+
+func (s *memSink) Close() error { return nil }
+
+func (s *memSink) Write(r *Record) error {
+	if s.block != nil {
+		<-s.block
+	}
+	s.mu.Lock()
+	s.records = append(s.records, r)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *memSink) list() []*Record {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*Record(nil), s.records...)
+}
+
+func newAuditServer(sink Sink) (j2rpc.RPCServer, *Auditor) {
+	s := j2rpc.New(&j2rpc.Option{SnakeNamespace: true})
+	s.Register(auditService{}, "user")
+	a := New(sink).SetUserResolver(func(*j2rpc.Context) string { return "bob" }).Attach(s)
+	return s, a
+}
+
+func TestAuditorHandle(t *testing.T) {
+	sink := new(memSink)
+	s, a := newAuditServer(sink)
+	a.Disable("user.ping")
+	tests := []struct {
+		method string
+		params interface{}
+		// want the record, nil if the method isn't audited
+		want *Record
+	}{
+		{
+			"user.login", []auditLogin{{Username: "bob", Password: "secret", Token: "t"}},
+			&Record{Method: "user.login", User: "bob", Params: json.RawMessage(`[{"username":"bob","password":"******"}]`)},
+		},
+		{
+			"user.login", []auditLogin{{Username: "bob", Password: "wrong"}},
+			&Record{
+				Method: "user.login", User: "bob", Params: json.RawMessage(`[{"username":"bob","password":"******"}]`),
+				ErrorCode: int(j2rpc.ErrForbidden), Error: "denied",
+			},
+		},
+		{"user.ping", nil, nil},
+	}
+	var want []*Record
+	for _, tt := range tests {
+		_, _ = s.Invoke(context.Background(), tt.method, tt.params)
+		if tt.want != nil {
+			want = append(want, tt.want)
+		}
+	}
+	require.NoError(t, a.Close())
+	got := sink.list()
+	require.Len(t, got, len(want))
+	for i, r := range got {
+		assert.False(t, r.Time.IsZero())
+		assert.JSONEq(t, string(want[i].Params), string(r.Params))
+		r.Time, r.Duration, r.Params = time.Time{}, 0, want[i].Params
+		assert.Equal(t, want[i], r)
+	}
+	// the records after Close are ignored
+	_, _ = s.Invoke(context.Background(), "user.login", []auditLogin{{Username: "bob"}})
+	assert.Len(t, sink.list(), len(want))
+	require.NoError(t, a.Close())
+}
+
+func TestAuditorSlowSink(t *testing.T) {
+	sink := &memSink{block: make(chan struct{})}
+	s, a := newAuditServer(sink)
+	calls := defaultBufferSize + 10
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < calls; i++ {
+			_, _ = s.Invoke(context.Background(), "user.ping", nil)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "the calls are stalled by the sink")
+	}
+	assert.NotZero(t, a.Dropped())
+
+	close(sink.block)
+	closed := make(chan error)
+	go func() { closed <- a.Close() }()
+	select {
+	case err := <-closed:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "Close is stalled")
+	}
+	assert.Equal(t, calls, len(sink.list())+int(a.Dropped()))
+}
@@ -0,0 +1,197 @@
+package audit
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/atcharles/glibs/j2rpc"
+)
+
+// RedactedValue replaces the values of the fields tagged `audit:"redact"`
+const RedactedValue = "******"
+
+// tagged caches whether a type has audit tags, the values of the other types are recorded as they are
+var tagged sync.Map
+
+// Redact returns the JSON value of v, the fields tagged `audit:"redact"` are replaced by RedactedValue
+// and the fields tagged `audit:"-"` are omitted.
+//
+//	type LoginParams struct {
+//		Username string `json:"username"`
+//		Password string `json:"password" audit:"redact"`
+//	}
+func Redact(v interface{}) interface{} { return redactValue(reflect.ValueOf(v)) }
+
+// RedactArgs redacts the arguments of a call
+func RedactArgs(args []reflect.Value) []interface{} {
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		values[i] = redactValue(arg)
+	}
+	return values
+}
+
+// redactValue ...
+func redactValue(rv reflect.Value) interface{} {
+	if !rv.IsValid() {
+		return nil
+	}
+	if !rv.CanInterface() {
+		return nil
+	}
+	if !hasAuditTag(rv.Type()) {
+		return rv.Interface()
+	}
+	bts, err := json.Marshal(rv.Interface())
+	if err != nil {
+		return nil
+	}
+	var generic interface{}
+	if err = json.Unmarshal(bts, &generic); err != nil {
+		return nil
+	}
+	return redactWalk(rv, generic)
+}
+
+// redactWalk walks the value and its JSON value in parallel
+func redactWalk(rv reflect.Value, generic interface{}) interface{} {
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return generic
+		}
+		rv = rv.Elem()
+	}
+	if !hasAuditTag(rv.Type()) {
+		return generic
+	}
+	switch rv.Kind() {
+	case reflect.Struct:
+		if m, ok := generic.(map[string]interface{}); ok {
+			redactStruct(rv, m)
+		}
+	case reflect.Slice, reflect.Array:
+		if s, ok := generic.([]interface{}); ok {
+			for i := 0; i < len(s) && i < rv.Len(); i++ {
+				s[i] = redactWalk(rv.Index(i), s[i])
+			}
+		}
+	case reflect.Map:
+		if m, ok := generic.(map[string]interface{}); ok {
+			iter := rv.MapRange()
+			for iter.Next() {
+				key := jsonMapKey(iter.Key())
+				if val, ok := m[key]; ok {
+					m[key] = redactWalk(iter.Value(), val)
+				}
+			}
+		}
+	}
+	return generic
+}
+
+// redactStruct ...
+func redactStruct(rv reflect.Value, m map[string]interface{}) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		name, ok := jsonFieldName(field)
+		if !ok {
+			continue
+		}
+		// the fields of embedded structs are in the same object
+		if field.Anonymous && name == "" && j2rpc.IndirectType(field.Type).Kind() == reflect.Struct {
+			fv := rv.Field(i)
+			for fv.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					break
+				}
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct {
+				redactStruct(fv, m)
+			}
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		val, ok := m[name]
+		if !ok {
+			continue
+		}
+		switch field.Tag.Get("audit") {
+		case "-":
+			delete(m, name)
+		case "redact":
+			m[name] = RedactedValue
+		default:
+			m[name] = redactWalk(rv.Field(i), val)
+		}
+	}
+}
+
+// hasAuditTag reports whether the type or the types it contains have audit tags
+func hasAuditTag(typ reflect.Type) bool {
+	if v, ok := tagged.Load(typ); ok {
+		return v.(bool)
+	}
+	has := hasAuditTagSeen(typ, make(map[reflect.Type]bool))
+	tagged.Store(typ, has)
+	return has
+}
+
+// hasAuditTagSeen ... the types on the stack of a recursive type are incomplete, only the top-level result is cached
+func hasAuditTagSeen(typ reflect.Type, seen map[reflect.Type]bool) bool {
+	if v, ok := tagged.Load(typ); ok {
+		return v.(bool)
+	}
+	if seen[typ] {
+		return false
+	}
+	seen[typ] = true
+	switch typ.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		return hasAuditTagSeen(typ.Elem(), seen)
+	case reflect.Interface:
+		// the dynamic type is checked while walking
+		return true
+	case reflect.Struct:
+		for i := 0; i < typ.NumField(); i++ {
+			field := typ.Field(i)
+			if !field.IsExported() && !field.Anonymous {
+				continue
+			}
+			if _, ok := field.Tag.Lookup("audit"); ok {
+				return true
+			}
+			if hasAuditTagSeen(field.Type, seen) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// jsonFieldName returns the JSON name of the field, "" for embedded structs without name,
+// false if the field isn't marshalled
+func jsonFieldName(field reflect.StructField) (string, bool) {
+	if !field.IsExported() && !field.Anonymous {
+		return "", false
+	}
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false
+	}
+	return strings.Split(tag, ",")[0], true
+}
+
+// jsonMapKey ...
+func jsonMapKey(key reflect.Value) string {
+	if key.Kind() == reflect.String {
+		return key.String()
+	}
+	bts, _ := json.Marshal(key.Interface())
+	return strings.Trim(string(bts), `"`)
+}
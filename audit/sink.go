@@ -0,0 +1,103 @@
+package audit
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"gorm.io/gorm"
+
+	"github.com/atcharles/glibs/mdb"
+	"github.com/atcharles/glibs/util"
+)
+
+// JSONLSink appends the records to a file, one JSON object per line
+type JSONLSink struct {
+	mu   sync.Mutex
+	file *os.File
+	enc  *json.Encoder
+}
+
+// Close ...
+func (s *JSONLSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
+
+// Write ...
+func (s *JSONLSink) Write(r *Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enc.Encode(r)
+}
+
+// NewJSONLSink opens the file in append mode, the default file is data/audit.jsonl under the root dir
+func NewJSONLSink(fileName ...string) (*JSONLSink, error) {
+	name := filepath.Join(util.RootDir(), "data", "audit.jsonl")
+	if len(fileName) > 0 && len(fileName[0]) > 0 {
+		name = fileName[0]
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	enc := json.NewEncoder(file)
+	enc.SetEscapeHTML(false)
+	return &JSONLSink{file: file, enc: enc}, nil
+}
+
+// AuditLog the table of GormSink
+type AuditLog struct {
+	ID         int64     `json:"id,omitempty" gorm:"primaryKey;autoIncrement:true;"`
+	Time       *mdb.Time `json:"time,omitempty" gorm:"notnull;index;"`
+	Method     string    `json:"method,omitempty" gorm:"size:128;notnull;index;"`
+	User       string    `json:"user,omitempty" gorm:"size:128;index;"`
+	Params     string    `json:"params,omitempty" gorm:"type:text;"`
+	Duration   int64     `json:"duration,omitempty" gorm:"comment:纳秒;"`
+	ErrorCode  int       `json:"error_code,omitempty"`
+	Error      string    `json:"error,omitempty" gorm:"type:text;"`
+	RemoteAddr string    `json:"remote_addr,omitempty" gorm:"size:64;"`
+	RequestID  string    `json:"request_id,omitempty" gorm:"size:64;"`
+}
+
+// GormSink inserts the records into the AuditLog table
+type GormSink struct {
+	db *gorm.DB
+}
+
+// Close the database is owned by the caller
+func (s *GormSink) Close() error { return nil }
+
+// Write ...
+func (s *GormSink) Write(r *Record) error {
+	return s.db.Create(&AuditLog{
+		Time:       util.NewJSONTimeOfTime(r.Time),
+		Method:     r.Method,
+		User:       r.User,
+		Params:     string(r.Params),
+		Duration:   int64(r.Duration),
+		ErrorCode:  r.ErrorCode,
+		Error:      r.Error,
+		RemoteAddr: r.RemoteAddr,
+		RequestID:  r.RequestID,
+	}).Error
+}
+
+// NewGormSink migrates the AuditLog table, nil db means mdb.DB
+func NewGormSink(db *gorm.DB) (*GormSink, error) {
+	if db == nil {
+		if err := mdb.DB.CheckDBNil(); err != nil {
+			return nil, err
+		}
+		db = mdb.DB.DB
+	}
+	if err := db.AutoMigrate(&AuditLog{}); err != nil {
+		return nil, err
+	}
+	return &GormSink{db: db.Session(&gorm.Session{SkipDefaultTransaction: true})}, nil
+}
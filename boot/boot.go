@@ -133,12 +133,15 @@ func StartupWithoutHttpServer() {
 		fn()
 	}
 
+	rpcOpt := j2rpc.SnakeOption.SetService(ServiceApp)
+	//rpc.discover and /rpc/discover are served only if server.discover is set
+	rpcOpt.EnableDiscover = config.Viper().GetBool("server.discover")
 	util.InjectPopulate(
 		giris.App,
 		config.C,
 		giris.IncJWT,
 		util.ValidatorInc,
-		j2rpc.New(j2rpc.SnakeOption.SetService(ServiceApp)),
+		j2rpc.New(rpcOpt),
 		util.ZapLogger("sys", config.Viper().GetString("app.log_level")),
 	)
 
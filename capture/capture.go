@@ -0,0 +1,302 @@
+package capture
+
+import (
+	"encoding/json"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tidwall/sjson"
+
+	"github.com/atcharles/glibs/audit"
+	"github.com/atcharles/glibs/j2rpc"
+)
+
+const (
+	// ReasonFailed the call returned an error
+	ReasonFailed = "failed"
+	// ReasonSlow the call took longer than Option.SlowThreshold
+	ReasonSlow = "slow"
+
+	defaultCapacity    = 256
+	defaultMaxBodySize = 64 << 10
+)
+
+// unredactedNote replaces the params and the body which can't be redacted, they may have secrets
+var unredactedNote = json.RawMessage(`"omitted, the params were not redacted"`)
+
+type (
+	// Entry a captured call, the params are redacted by the audit tags
+	Entry struct {
+		ID     uint64    `json:"id"`
+		Time   time.Time `json:"time"`
+		Method string    `json:"method"`
+		Reason string    `json:"reason"`
+		//Duration nanoseconds
+		Duration time.Duration `json:"duration"`
+		//Body the decrypted request, its params are replaced by the redacted Params
+		Body json.RawMessage `json:"body,omitempty"`
+		//Params the parsed arguments redacted, a note if they were not parsed
+		Params     json.RawMessage `json:"params,omitempty"`
+		Result     json.RawMessage `json:"result,omitempty"`
+		Error      *j2rpc.Error    `json:"error,omitempty"`
+		RemoteAddr string          `json:"remote_addr,omitempty"`
+		RequestID  string          `json:"request_id,omitempty"`
+	}
+
+	// Option ...
+	Option struct {
+		//Capacity max number of entries kept, the oldest ones are dropped, default is 256
+		Capacity int
+		//SlowThreshold the calls slower than it are captured, 0 captures the failed calls only
+		SlowThreshold time.Duration
+		//FailedRate ratio of the failed calls captured, default is 1 (all), negative captures none
+		FailedRate float64
+		//SlowRate ratio of the slow calls captured, default is 1 (all), negative captures none
+		SlowRate float64
+		//MethodLimit max number of entries of each method, the oldest ones are dropped, 0 is no limit
+		MethodLimit int
+		//MethodLimits the limits of methods (namespace.method) or namespaces, they take precedence over MethodLimit
+		MethodLimits map[string]int
+		//MaxBodySize the body, params or result larger than it is replaced by a note, default is 64KB
+		MaxBodySize int
+	}
+
+	// Redactor edits the entry before it's captured, e.g. to remove the secrets of the body
+	Redactor func(e *Entry)
+
+	// Query the filter of Capturer.List
+	Query struct {
+		//Method the method (namespace.method) or namespace
+		Method string `json:"method"`
+		Reason string `json:"reason"`
+		//BeforeID the entries older than it, for paging
+		BeforeID uint64 `json:"before_id"`
+		//Limit default is 20
+		Limit int `json:"limit"`
+	}
+)
+
+// Capturer keeps the failed and slow calls of the servers in memory, see Attach and Register
+type Capturer struct {
+	mu  sync.Mutex
+	opt Option
+	//entries oldest first
+	entries   []*Entry
+	seq       uint64
+	redactors []Redactor
+	//namespace of the admin service, its calls are not captured
+	namespace string
+}
+
+// AddRedactor ...
+func (c *Capturer) AddRedactor(redactors ...Redactor) *Capturer {
+	c.mu.Lock()
+	c.redactors = append(c.redactors, redactors...)
+	c.mu.Unlock()
+	return c
+}
+
+// Attach captures the calls of s
+func (c *Capturer) Attach(s j2rpc.RPCServer) *Capturer {
+	s.OnComplete(c.Handle)
+	return c
+}
+
+// Clear removes all entries
+func (c *Capturer) Clear() {
+	c.mu.Lock()
+	c.entries = nil
+	c.mu.Unlock()
+}
+
+// Get returns the entry, nil if it's dropped
+func (c *Capturer) Get(id uint64) *Entry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, e := range c.entries {
+		if e.ID == id {
+			return e
+		}
+	}
+	return nil
+}
+
+// Handle is the j2rpc.CompleteHandler of the capturer
+func (c *Capturer) Handle(ctx *j2rpc.Context) {
+	msg := ctx.Msg()
+	if len(msg.Method) == 0 || c.isAdmin(msg.Method) {
+		return
+	}
+	e := &Entry{Time: time.Now(), Method: msg.Method}
+	if _val, ok := ctx.Store.Load(j2rpc.StartTimeKey); ok {
+		e.Time = time.Unix(0, _val.(int64))
+	}
+	if _val, ok := ctx.Store.Load(j2rpc.TimeUsedKey); ok {
+		e.Duration = time.Duration(_val.(int64))
+	}
+	var rate float64
+	switch {
+	case msg.Error != nil:
+		e.Reason, rate = ReasonFailed, c.opt.FailedRate
+	case c.opt.SlowThreshold > 0 && e.Duration >= c.opt.SlowThreshold:
+		e.Reason, rate = ReasonSlow, c.opt.SlowRate
+	default:
+		return
+	}
+	if rate < 1 && rand.Float64() >= rate {
+		return
+	}
+	e.Error = msg.Error
+	e.Params, e.Body = redactParams(ctx.Body, msg.Params, ctx.Args())
+	switch result := ctx.Result().(type) {
+	case nil:
+	case json.RawMessage:
+		e.Result = append(json.RawMessage(nil), result...)
+	default:
+		e.Result, _ = json.Marshal(result)
+	}
+	if req := ctx.Request(); req != nil {
+		e.RemoteAddr = req.RemoteAddr
+		e.RequestID = req.Header.Get("X-Request-Id")
+	}
+	if w := ctx.Writer(); w != nil && len(w.Header().Get("request-id")) > 0 {
+		e.RequestID = w.Header().Get("request-id")
+	}
+	e.Body = c.limitSize(e.Body)
+	e.Params = c.limitSize(e.Params)
+	e.Result = c.limitSize(e.Result)
+	c.mu.Lock()
+	redactors := c.redactors
+	c.mu.Unlock()
+	for _, redact := range redactors {
+		redact(e)
+	}
+	c.add(e)
+}
+
+// List returns the entries without body, params and result, newest first
+func (c *Capturer) List(q *Query) []*Entry {
+	if q == nil {
+		q = new(Query)
+	}
+	limit := q.Limit
+	if limit <= 0 {
+		limit = 20
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	list := make([]*Entry, 0, limit)
+	for i := len(c.entries) - 1; i >= 0 && len(list) < limit; i-- {
+		e := c.entries[i]
+		if q.BeforeID > 0 && e.ID >= q.BeforeID {
+			continue
+		}
+		if len(q.Reason) > 0 && e.Reason != q.Reason {
+			continue
+		}
+		if len(q.Method) > 0 && e.Method != q.Method && !strings.HasPrefix(e.Method, q.Method+".") {
+			continue
+		}
+		summary := *e
+		summary.Body, summary.Params, summary.Result = nil, nil, nil
+		list = append(list, &summary)
+	}
+	return list
+}
+
+// add drops the oldest entry of the method if it's over the limit, and the oldest one if it's full
+func (c *Capturer) add(e *Entry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.seq++
+	e.ID = c.seq
+	if limit := c.methodLimit(e.Method); limit > 0 {
+		count, oldest := 0, -1
+		for i, v := range c.entries {
+			if v.Method == e.Method {
+				if oldest < 0 {
+					oldest = i
+				}
+				count++
+			}
+		}
+		if count >= limit {
+			c.entries = append(c.entries[:oldest], c.entries[oldest+1:]...)
+		}
+	}
+	if len(c.entries) >= c.opt.Capacity {
+		c.entries = append(c.entries[:0], c.entries[len(c.entries)-c.opt.Capacity+1:]...)
+	}
+	c.entries = append(c.entries, e)
+}
+
+// isAdmin ...
+func (c *Capturer) isAdmin(method string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.namespace) > 0 && strings.HasPrefix(method, c.namespace+".")
+}
+
+// limitSize ...
+func (c *Capturer) limitSize(raw json.RawMessage) json.RawMessage {
+	if len(raw) <= c.opt.MaxBodySize {
+		return raw
+	}
+	return json.RawMessage(strconv.Quote("truncated, " + strconv.Itoa(len(raw)) + " bytes"))
+}
+
+// redactParams returns the params redacted by the audit tags, and the body with them.
+// The params which can't be redacted, e.g. they were not parsed, and the body are replaced by a note.
+func redactParams(body, raw json.RawMessage, args []reflect.Value) (params, out json.RawMessage) {
+	if len(args) == 0 {
+		switch strings.TrimSpace(string(raw)) {
+		case "", "null", "[]", "{}":
+			return nil, append(json.RawMessage(nil), body...)
+		}
+		return unredactedNote, unredactedNote
+	}
+	params, err := json.Marshal(audit.RedactArgs(args))
+	if err != nil {
+		return unredactedNote, unredactedNote
+	}
+	if out, err = sjson.SetRawBytes(append(json.RawMessage(nil), body...), "params", params); err != nil {
+		return params, unredactedNote
+	}
+	return params, out
+}
+
+// methodLimit ...
+func (c *Capturer) methodLimit(method string) int {
+	if limit, ok := c.opt.MethodLimits[method]; ok {
+		return limit
+	}
+	if limit, ok := c.opt.MethodLimits[strings.SplitN(method, ".", 2)[0]]; ok {
+		return limit
+	}
+	return c.opt.MethodLimit
+}
+
+// New ...
+func New(opts ...*Option) *Capturer {
+	c := &Capturer{}
+	if len(opts) > 0 && opts[0] != nil {
+		c.opt = *opts[0]
+	}
+	if c.opt.Capacity <= 0 {
+		c.opt.Capacity = defaultCapacity
+	}
+	if c.opt.FailedRate == 0 {
+		c.opt.FailedRate = 1
+	}
+	if c.opt.SlowRate == 0 {
+		c.opt.SlowRate = 1
+	}
+	if c.opt.MaxBodySize <= 0 {
+		c.opt.MaxBodySize = defaultMaxBodySize
+	}
+	return c
+}
@@ -0,0 +1,62 @@
+package capture
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/atcharles/glibs/j2rpc"
+)
+
+type (
+	loginService struct{}
+
+	loginParams struct {
+		Username string `json:"username"`
+		Password string `json:"password" audit:"redact"`
+	}
+)
+
+func (loginService) Login(_ context.Context, p loginParams) error { return errors.New("denied") }
+
+func TestHandleRedactsParams(t *testing.T) {
+	s := j2rpc.New(&j2rpc.Option{SnakeNamespace: true})
+	s.Register(loginService{}, "user")
+	c := New().Attach(s)
+	tests := []struct {
+		name   string
+		params string
+		// want the captured params and body contain it, and neither contains the secret
+		want string
+	}{
+		{"parsed", `[{"username":"bob","password":"secret"}]`, `"password":"******"`},
+		{"unparsed", `[{"username":"bob","password":"secret"},"extra"]`, string(unredactedNote)},
+		{"invalid", `{"password":"secret"`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c.Clear()
+			body := `{"jsonrpc":"2.0","id":1,"method":"user.login","params":` + tt.params + `}`
+			s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+			entries := c.List(&Query{})
+			if tt.want == "" {
+				// the body isn't a message, the call has no method
+				assert.Empty(t, entries)
+				return
+			}
+			require.Len(t, entries, 1)
+			e := c.Get(entries[0].ID)
+			require.NotNil(t, e)
+			for _, raw := range []string{string(e.Params), string(e.Body)} {
+				assert.NotContains(t, raw, "secret")
+				assert.Contains(t, raw, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package capture
+
+import (
+	"context"
+
+	"github.com/atcharles/glibs/j2rpc"
+)
+
+// DefaultNamespace the namespace of the admin service
+const DefaultNamespace = "capture"
+
+// Service the admin namespace to list and fetch the captured entries,
+// its methods require the authentication and are hidden from the discovery
+type Service struct {
+	c    *Capturer
+	meta j2rpc.MethodMeta
+}
+
+// Clear removes all entries
+func (s *Service) Clear(_ context.Context) error {
+	s.c.Clear()
+	return nil
+}
+
+// Get returns the entry with its body, params and result
+func (s *Service) Get(_ context.Context, id uint64) (*Entry, error) {
+	e := s.c.Get(id)
+	if e == nil {
+		return nil, j2rpc.NewError(j2rpc.ErrBadParams, "entry not found")
+	}
+	return e, nil
+}
+
+func (s *Service) J2rpcMethodMeta() map[string]j2rpc.MethodMeta {
+	return map[string]j2rpc.MethodMeta{"Clear": s.meta, "Get": s.meta, "List": s.meta}
+}
+
+func (s *Service) J2rpcParamNames() map[string][]string {
+	return map[string][]string{"Get": {"id"}}
+}
+
+// List returns the entries without body, params and result, newest first
+func (s *Service) List(_ context.Context, q *Query) ([]*Entry, error) { return s.c.List(q), nil }
+
+// Register registers the admin service of the capturer to s, namespace default is DefaultNamespace.
+// The methods require the authentication, and one of the roles if they are given.
+func (c *Capturer) Register(s j2rpc.RPCServer, namespace string, roles ...string) *Capturer {
+	if len(namespace) == 0 {
+		namespace = DefaultNamespace
+	}
+	c.mu.Lock()
+	c.namespace = namespace
+	c.mu.Unlock()
+	s.Register(&Service{c: c, meta: j2rpc.MethodMeta{Auth: true, Roles: roles, Hidden: true}}, namespace)
+	return c
+}
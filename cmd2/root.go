@@ -15,6 +15,8 @@ func init() {
 	RootCmd.AddCommand(startCmd)
 	startCmd.Flags().BoolVarP(&daemon, "daemon", "d", false, "run as daemon")
 	RootCmd.AddCommand(stopCmd)
+	RootCmd.AddCommand(tsCmd)
+	tsCmd.Flags().StringVarP(&tsOutput, "output", "o", "", "output file, stdout if it's empty")
 }
 
 var RootCmd = &cobra.Command{
@@ -0,0 +1,38 @@
+package cmd2
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	j2rpc "github.com/atcharles/glibs/j2rpc2"
+)
+
+// TSServerFunc returns the server whose routes are generated by the ts command,
+// the methods must be registered, the server isn't started
+var TSServerFunc func() j2rpc.Server
+
+var tsOutput string
+var tsCmd = &cobra.Command{
+	Use:   "ts",
+	Short: "generate the TypeScript client of the rpc methods",
+	RunE: func(*cobra.Command, []string) error {
+		if TSServerFunc == nil {
+			return fmt.Errorf("TSServerFunc is not set")
+		}
+		data, err := j2rpc.GenerateTypeScript(TSServerFunc())
+		if err != nil {
+			return err
+		}
+		if len(tsOutput) == 0 || tsOutput == "-" {
+			_, err = os.Stdout.Write(data)
+			return err
+		}
+		if err = os.MkdirAll(filepath.Dir(tsOutput), 0755); err != nil {
+			return err
+		}
+		return os.WriteFile(tsOutput, data, 0644)
+	},
+}
@@ -0,0 +1,47 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"io"
+)
+
+// AESGCMDecrypt hex decodes a piece of data and then decrypts and authenticates it using GCM mode.
+func AESGCMDecrypt(cipherKey, ciphertext, additionalData []byte, useBase64 ...bool) ([]byte, error) {
+	ciphertext, err := decode(ciphertext, useBase64)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(cipherKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < aead.NonceSize()+aead.Overhead() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce := ciphertext[:aead.NonceSize()]
+	return aead.Open(nil, nonce, ciphertext[aead.NonceSize():], additionalData)
+}
+
+// AESGCMEncrypt uses GCM mode to encrypt a piece of data with a random nonce and then encodes it in hex.
+func AESGCMEncrypt(cipherKey, plaintext, additionalData []byte, useBase64 ...bool) ([]byte, error) {
+	aead, err := newGCM(cipherKey)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return encode(aead.Seal(nonce, nonce, plaintext, additionalData), useBase64), nil
+}
+
+func newGCM(cipherKey []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(cipherKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
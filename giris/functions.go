@@ -19,3 +19,10 @@ func RPCServer2IrisHandler(h j2rpc.RPCServer) iris.Handler {
 		h.Handler(h.GetContext().BeginWithContext(c, c.ResponseWriter(), c.Request()))
 	}
 }
+
+// RPCServer2IrisWebsocketHandler the calls of the connection get the iris.Context of the upgrade request
+func RPCServer2IrisWebsocketHandler(h j2rpc.RPCServer) iris.Handler {
+	return func(c iris.Context) {
+		h.WebsocketHandler(h.GetContext().BeginWithContext(c, c.ResponseWriter(), c.Request()))
+	}
+}
@@ -83,16 +83,39 @@ func (a *AppStart) RouteApp() iris.Party {
 		a.RPCServerFunc(a.RPC)
 	}
 	a.RPC.SetLogger(a.WebLogger.WebLogger())
+	if a.Validator != nil {
+		a.RPC.Opt().Validator = a.Validator
+	}
+	if a.RPC.Opt().UserResolver == nil {
+		a.RPC.Opt().UserResolver = func(c *j2rpc.Context) interface{} {
+			if ic, ok := c.Context.(iris.Context); ok {
+				return ic.Values().Get(ContextUser)
+			}
+			return nil
+		}
+	}
 	if str := strings.TrimSpace(config.Viper().GetString("server.crypto")); str != "" {
 		a.RPC.Opt().CryptoKey = str
 	}
+	if str := strings.TrimSpace(config.Viper().GetString("server.envelope_key")); str != "" {
+		a.RPC.Opt().Envelope = &j2rpc.EnvelopeOption{PrivateKey: str}
+	}
 	p.Post("/rpc", iris.Compression, RPCServer2IrisHandler(a.RPC))
+	if a.RPC.Opt().EnableDiscover {
+		p.Get("/rpc/discover", HttpHandler2IrisHandler(j2rpc.DiscoverHandler(a.RPC)))
+	}
+	p.Get("/rpc/ws", RPCServer2IrisWebsocketHandler(a.RPC))
 	a.rootParty = p
 	return p
 }
 
 // Shutdown ...
 func (a *AppStart) Shutdown() {
+	ctx, cancel := stdContext.WithTimeout(stdContext.Background(), j2rpc.StopPendingRequestTimeout)
+	defer cancel()
+	if err := a.RPC.Stop(ctx); err != nil {
+		log.Printf("RPC server stop error: %s\n", err.Error())
+	}
 	close(a.stop)
 }
 
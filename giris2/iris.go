@@ -71,6 +71,8 @@ func (a *AppStart) RouteApp() iris.Party {
 	p.Get("/captcha", a.Captcha.Captcha())
 	if a.RPC != nil {
 		p.Post("/rpc", RPCServer2IrisHandler(a.RPC))
+		//the cacheable methods only
+		p.Get("/rpc", RPCServer2IrisHandler(a.RPC))
 	}
 	return p
 }
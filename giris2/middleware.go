@@ -2,6 +2,7 @@ package giris2
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"time"
 
@@ -11,6 +12,7 @@ import (
 	"github.com/kataras/iris/v12/middleware/rate"
 
 	"github.com/atcharles/glibs/j2rpc"
+	"github.com/atcharles/glibs/trace"
 	"github.com/atcharles/glibs/util"
 )
 
@@ -88,3 +90,23 @@ func RateLimiter() context.Handler {
 		}),
 	)
 }
+
+// Tracing starts a server span per request, it continues the trace of the traceparent header.
+// The span is in the context of the request, j2rpc.Tracing starts its spans as children.
+func Tracing(tracer *trace.Tracer) iris.Handler {
+	return func(c iris.Context) {
+		req := c.Request()
+		ctx, span := tracer.Start(trace.Extract(req.Context(), req.Header), "HTTP "+req.Method+" "+c.Path(), trace.KindServer)
+		span.SetAttr("http.method", req.Method).SetAttr("http.target", req.URL.RequestURI())
+		c.ResetRequest(req.WithContext(ctx))
+		defer func() {
+			status := c.GetStatusCode()
+			span.SetAttr("http.status_code", int64(status))
+			if status >= http.StatusInternalServerError {
+				span.SetError(errors.New(http.StatusText(status)))
+			}
+			span.Finish()
+		}()
+		c.Next()
+	}
+}
@@ -0,0 +1,51 @@
+package gresty
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+
+	"github.com/go-resty/resty/v2"
+
+	"github.com/atcharles/glibs/trace"
+)
+
+// Trace starts a client span per attempt of the requests, it's the child of the span of request.SetContext,
+// and its traceparent is sent to the server
+func (r *Resty) Trace(tracer *trace.Tracer) *Resty {
+	//spans the span of the current attempt of each request
+	spans := new(sync.Map)
+	finish := func(request *resty.Request, err error) {
+		if val, ok := spans.LoadAndDelete(request); ok {
+			val.(*trace.Span).SetError(err).Finish()
+		}
+	}
+	c := r.Client()
+	c.OnBeforeRequest(func(_ *resty.Client, request *resty.Request) error {
+		//the previous attempt failed
+		finish(request, errors.New("retried"))
+		_, span := tracer.Start(request.Context(), "HTTP "+request.Method, trace.KindClient)
+		span.SetAttr("http.method", request.Method).SetAttr("http.url", request.URL)
+		if request.Attempt > 1 {
+			span.SetAttr("http.resend_count", int64(request.Attempt-1))
+		}
+		request.SetHeader(trace.TraceparentHeader, span.Context.Traceparent())
+		spans.Store(request, span)
+		return nil
+	})
+	c.OnAfterResponse(func(_ *resty.Client, response *resty.Response) error {
+		val, ok := spans.LoadAndDelete(response.Request)
+		if !ok {
+			return nil
+		}
+		span := val.(*trace.Span)
+		span.SetAttr("http.status_code", int64(response.StatusCode()))
+		if response.StatusCode() >= http.StatusInternalServerError {
+			span.SetError(errors.New(response.Status()))
+		}
+		span.Finish()
+		return nil
+	})
+	c.OnError(finish)
+	return r
+}
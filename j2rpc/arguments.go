@@ -0,0 +1,128 @@
+package j2rpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// argDecoder decodes the params of a callback in one pass, it's built once when the callback is registered.
+// The errors are reported by ParseArguments, which decodes the arguments one by one.
+type argDecoder struct {
+	types []reflect.Type
+	names []string
+	//object the struct of the named arguments, field i is argument i, nil if there are no valid names
+	object reflect.Type
+	//single the params object is decoded into the only argument, which is a struct
+	single bool
+}
+
+// newArgDecoder ...
+func newArgDecoder(types []reflect.Type, names []string) *argDecoder {
+	d := &argDecoder{types: types, names: names}
+	if len(names) == 0 {
+		d.single = len(types) == 1 && IndirectType(types[0]).Kind() == reflect.Struct
+		return d
+	}
+	seen := make(map[string]struct{}, len(names))
+	fields := make([]reflect.StructField, 0, len(types))
+	for i := 0; i < len(types) && i < len(names); i++ {
+		name := names[i]
+		if _, ok := seen[name]; ok || len(name) == 0 || name == "-" {
+			return d
+		}
+		seen[name] = struct{}{}
+		fields = append(fields, reflect.StructField{
+			Name: "A" + strconv.Itoa(i),
+			Type: types[i],
+			Tag:  reflect.StructTag(`json:"` + name + `"`),
+		})
+	}
+	d.object = reflect.StructOf(fields)
+	return d
+}
+
+// decode works like ParseArguments
+func (d *argDecoder) decode(rawArgs json.RawMessage) ([]reflect.Value, error) {
+	trimmed := bytes.TrimLeft(rawArgs, " \t\r\n")
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		return d.decodeObject(trimmed)
+	}
+	return d.decodeArray(trimmed)
+}
+
+// decodeArray decodes the array into the pointers of the arguments, json keeps the non-nil pointers
+// held by the interfaces of the slice, and sets the interface to nil for a null value.
+func (d *argDecoder) decodeArray(rawArgs json.RawMessage) ([]reflect.Value, error) {
+	args := make([]reflect.Value, len(d.types))
+	if len(rawArgs) == 0 || bytes.Equal(rawArgs, []byte("null")) {
+		for i, typ := range d.types {
+			args[i] = zeroArgument(typ)
+		}
+		return args, nil
+	}
+	if rawArgs[0] != '[' {
+		return nil, errors.New("non-array args")
+	}
+	values := make([]reflect.Value, len(d.types))
+	pointers := make([]interface{}, len(d.types))
+	for i, typ := range d.types {
+		values[i] = reflect.New(typ)
+		pointers[i] = values[i].Interface()
+	}
+	if err := json.Unmarshal(rawArgs, &pointers); err != nil {
+		return ParsePositionalArguments(rawArgs, d.types)
+	}
+	if len(pointers) > len(d.types) {
+		return nil, fmt.Errorf("too many arguments, want at most %d", len(d.types))
+	}
+	for i, typ := range d.types {
+		switch {
+		case i >= len(pointers):
+			args[i] = zeroArgument(typ)
+		case pointers[i] == nil:
+			if typ.Kind() != reflect.Ptr {
+				return nil, fmt.Errorf("missing value for required argument %d", i)
+			}
+			args[i] = reflect.Zero(typ)
+		default:
+			args[i] = values[i].Elem()
+		}
+	}
+	return args, nil
+}
+
+// decodeObject ...
+func (d *argDecoder) decodeObject(rawArgs json.RawMessage) ([]reflect.Value, error) {
+	if d.object == nil && !d.single {
+		return ParseArguments(rawArgs, d.types, d.names)
+	}
+	if d.single {
+		agv := reflect.New(d.types[0])
+		if err := json.Unmarshal(rawArgs, agv.Interface()); err != nil {
+			return nil, fmt.Errorf("invalid argument 0: %s", err.Error())
+		}
+		return []reflect.Value{agv.Elem()}, nil
+	}
+	obj := reflect.New(d.object)
+	if err := json.Unmarshal(rawArgs, obj.Interface()); err != nil {
+		return ParseArguments(rawArgs, d.types, d.names)
+	}
+	args := make([]reflect.Value, len(d.types))
+	for i, typ := range d.types {
+		if i >= d.object.NumField() {
+			args[i] = zeroArgument(typ)
+			continue
+		}
+		field := obj.Elem().Field(i)
+		if field.Kind() == reflect.Ptr && field.IsNil() {
+			args[i] = zeroArgument(typ)
+			continue
+		}
+		args[i] = field
+	}
+	return args, nil
+}
@@ -0,0 +1,108 @@
+package j2rpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/atcharles/glibs/util"
+)
+
+// batchWriter the writer of a batch element, the headers are merged into the response after the batch,
+// so the concurrent elements don't share the header map
+type batchWriter struct {
+	http.ResponseWriter
+	header http.Header
+}
+
+func (b *batchWriter) Header() http.Header { return b.header }
+
+// merge ...
+func (b *batchWriter) merge() {
+	dst := b.ResponseWriter.Header()
+	for key, values := range b.header {
+		for _, value := range values {
+			if !util.Contains(dst.Values(key), value) {
+				dst.Add(key, value)
+			}
+		}
+	}
+}
+
+// handleBatch runs every element of a batch request through the middleware chain and the callback,
+// each with its own pooled Context, and encodes the responses in request order into a pooled buffer,
+// nil if all the elements are notifications.
+func (s *server) handleBatch(ctx *Context, body []byte) *bytes.Buffer {
+	var elements []json.RawMessage
+	if err := json.Unmarshal(body, &elements); err != nil {
+		return s.encodeMessage(RPCError(NewError(ErrParse, "请求错误")).output())
+	}
+	if len(elements) == 0 {
+		return s.encodeMessage(RPCError(NewError(ErrInvalidRequest, "empty batch")).output())
+	}
+	if limit := s.Opt().BatchLimit; limit > 0 && len(elements) > limit {
+		return s.encodeMessage(RPCError(NewError(ErrInvalidRequest, fmt.Sprintf("batch too large (%d>%d)", len(elements), limit))).output())
+	}
+	results := make([]*bytes.Buffer, len(elements))
+	writers := make([]*batchWriter, len(elements))
+	sem := make(chan struct{}, s.Opt().batchConcurrency())
+	var wg sync.WaitGroup
+	for i, element := range elements {
+		wg.Add(1)
+		sem <- struct{}{}
+		writers[i] = &batchWriter{ResponseWriter: ctx.Writer(), header: make(http.Header)}
+		go func(i int, element json.RawMessage) {
+			defer func() { <-sem; wg.Done() }()
+			results[i] = s.handleBatchElement(ctx, writers[i], element)
+		}(i, element)
+	}
+	wg.Wait()
+	for _, w := range writers {
+		w.merge()
+	}
+	// notifications have no entry in the response array
+	var buf *bytes.Buffer
+	for _, result := range results {
+		if result == nil {
+			continue
+		}
+		if buf == nil {
+			buf = acquireBuffer()
+			buf.WriteByte('[')
+		} else {
+			buf.WriteByte(',')
+		}
+		buf.Write(result.Bytes())
+		releaseBuffer(result)
+	}
+	if buf != nil {
+		buf.WriteByte(']')
+	}
+	return buf
+}
+
+// handleBatchElement returns the encoded response of the element, nil for a notification
+func (s *server) handleBatchElement(parent *Context, w http.ResponseWriter, element json.RawMessage) (out *bytes.Buffer) {
+	c := s.GetContext().BeginWithContext(parent.Context, w, parent.Request())
+	defer c.Release()
+	c.fields = parent.fields
+	defer func() {
+		if p := recover(); p != nil {
+			out = s.encodeMessage(RPCError(s.stack(p, c.Msg().Method)).output())
+		}
+	}()
+	err := s.handle(c, element)
+	if err != nil {
+		c.Msg().setError(err, s.Opt().ErrorRegistry)
+	}
+	if c.Msg().isNotification() {
+		s.complete(c)
+		s.logNotificationError(c.Msg(), err)
+		return nil
+	}
+	out = s.encodeResponse(c)
+	s.complete(c)
+	return
+}
@@ -0,0 +1,153 @@
+package j2rpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type batchService struct{ clientService }
+
+func (*batchService) Panic(_ context.Context) error { panic("boom") }
+
+// batchResponse an element of the response, Error is the code or 0
+type batchResponse struct {
+	ID     json.RawMessage
+	Result string
+	Error  ErrorCode
+}
+
+func TestBatch(t *testing.T) {
+	svc := new(batchService)
+	s := New(&Option{SnakeNamespace: true, BatchLimit: 4})
+	s.Register(svc, "calc")
+	call := func(id, method, params string) string {
+		if len(id) > 0 {
+			id = `"id":` + id + `,`
+		}
+		return `{"jsonrpc":"2.0",` + id + `"method":"` + method + `","params":` + params + `}`
+	}
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		// want the responses in order, nil for none
+		want     []batchResponse
+		notified int64
+	}{
+		{
+			name: "calls and notification",
+			body: "[" + strings.Join([]string{
+				call("1", "calc.add", "[1,2]"),
+				call("", "calc.notice", "[2]"),
+				call(`"b"`, "calc.fail", "[]"),
+				call("3", "calc.none", "[]"),
+			}, ",") + "]",
+			status: http.StatusOK,
+			want: []batchResponse{
+				{ID: json.RawMessage("1"), Result: "3"},
+				{ID: json.RawMessage(`"b"`), Error: ErrForbidden},
+				{ID: json.RawMessage("3"), Error: ErrNoMethod},
+			},
+			notified: 2,
+		},
+		{
+			name:     "notifications only",
+			body:     "[" + call("", "calc.notice", "[1]") + "," + call("", "calc.notice", "[1]") + "]",
+			status:   http.StatusNoContent,
+			notified: 2,
+		},
+		{
+			name:     "notification",
+			body:     call("", "calc.notice", "[3]"),
+			status:   http.StatusNoContent,
+			notified: 3,
+		},
+		{
+			name:   "failed notification",
+			body:   call("", "calc.fail", "[]"),
+			status: http.StatusNoContent,
+		},
+		{
+			name:   "unknown notification",
+			body:   call("", "calc.none", "[]"),
+			status: http.StatusNoContent,
+		},
+		{
+			name:   "panic",
+			body:   "[" + call("1", "calc.panic", "[]") + "," + call("2", "calc.add", "[2,2]") + "]",
+			status: http.StatusOK,
+			want: []batchResponse{
+				{ID: json.RawMessage("1"), Error: ErrInternal},
+				{ID: json.RawMessage("2"), Result: "4"},
+			},
+		},
+		{
+			name:   "invalid element",
+			body:   "[1," + call("2", "calc.add", "[1,1]") + "]",
+			status: http.StatusOK,
+			want: []batchResponse{
+				{ID: json.RawMessage("null"), Error: ErrParse},
+				{ID: json.RawMessage("2"), Result: "2"},
+			},
+		},
+		{
+			name:   "empty",
+			body:   "[]",
+			status: http.StatusOK,
+			want:   []batchResponse{{ID: json.RawMessage("null"), Error: ErrInvalidRequest}},
+		},
+		{
+			name:   "too large",
+			body:   "[" + strings.TrimSuffix(strings.Repeat(call("1", "calc.add", "[1,1]")+",", 5), ",") + "]",
+			status: http.StatusOK,
+			want:   []batchResponse{{ID: json.RawMessage("null"), Error: ErrInvalidRequest}},
+		},
+		{
+			name:   "invalid json",
+			body:   "[" + call("1", "calc.add", "[1,1]"),
+			status: http.StatusOK,
+			want:   []batchResponse{{ID: json.RawMessage("null"), Error: ErrParse}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc.notified.Store(0)
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body)))
+			require.Equal(t, tt.status, w.Code, w.Body.String())
+			assert.Equal(t, tt.notified, svc.notified.Load())
+			if tt.want == nil {
+				assert.Empty(t, w.Body.String())
+				return
+			}
+			var messages []*RPCMessage
+			if body := w.Body.Bytes(); isBatchBody(body) {
+				require.NoError(t, json.Unmarshal(body, &messages))
+			} else {
+				msg := new(RPCMessage)
+				require.NoError(t, json.Unmarshal(body, msg))
+				messages = append(messages, msg)
+			}
+			got := make([]batchResponse, len(messages))
+			for i, msg := range messages {
+				got[i].ID = msg.ID
+				if len(got[i].ID) == 0 {
+					got[i].ID = json.RawMessage("null")
+				}
+				if msg.Error != nil {
+					got[i].Error = msg.Error.Code
+					continue
+				}
+				got[i].Result = string(msg.Result)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -1,8 +1,14 @@
 package j2rpc
 
 import (
+	"context"
+	"errors"
 	"reflect"
+	"strings"
+	"sync/atomic"
 	"time"
+
+	"github.com/atcharles/glibs/util"
 )
 
 type callback struct {
@@ -17,24 +23,39 @@ type callback struct {
 	fn reflect.Value
 	//input argument types
 	argTypes []reflect.Type
+	//input argument names, used to map the by-name params
+	argNames []string
+	//decoder of the params, built from argTypes and argNames
+	decoder *argDecoder
 	//method's first argument is a context (not included in argTypes)
 	hasCtx bool
+	//the context argument is *Context
+	hasRPCCtx bool
 	//err return idx, of -1 when method cannot return error
 	errPos int
+	//skip the validation of arguments
+	skipValidate bool
+	//timeouts declared by the receiver, for the method and for the whole namespace
+	methodTimeout    time.Duration
+	namespaceTimeout time.Duration
+	//metadata declared by the receiver
+	meta *MethodMeta
 }
 
 // call invokes the callback.
 func (c *callback) call(ctx *Context, args []reflect.Value) (res interface{}, err error) {
-	//Create the argument slice.
-	fullArgs := make([]reflect.Value, 0, 2+len(args))
-	if c.rcv.IsValid() {
-		fullArgs = append(fullArgs, c.rcv)
-	}
-	if c.hasCtx {
-		fullArgs = append(fullArgs, reflect.ValueOf(ctx.Context))
+	//A subscription lives after the call, its method gets the context of the subscription without deadline.
+	//Otherwise bound the execution time, the callback's context is cancelled at the deadline.
+	if ctx.subCtx != nil {
+		parent := ctx.Context
+		defer func() { ctx.Context = parent }()
+		ctx.Context = ctx.subCtx
+	} else if timeout := c.timeout(); timeout > 0 {
+		parent := ctx.Context
+		timeoutCtx, cancel := context.WithTimeout(parent, timeout)
+		defer func() { cancel(); ctx.Context = parent }()
+		ctx.Context = timeoutCtx
 	}
-	fullArgs = append(fullArgs, args...)
-
 	//Catch panic while running the callback.
 	defer func() {
 		if p := recover(); p != nil {
@@ -57,7 +78,10 @@ func (c *callback) call(ctx *Context, args []reflect.Value) (res interface{}, er
 	}
 
 	//Run the callback.
-	results := c.fn.Call(fullArgs)
+	results, err := c.invoke(ctx, args)
+	if err != nil {
+		return
+	}
 
 	ctx.Store.Store(EndTimeKey, time.Now().UnixNano())
 
@@ -95,6 +119,108 @@ func (c *callback) call(ctx *Context, args []reflect.Value) (res interface{}, er
 	return rv.Interface(), err
 }
 
+// invoke runs the function, when the context has a deadline the function runs in its own goroutine,
+// and the call returns ErrTimeout at the deadline even if the function is still running.
+// The goroutine gets a detached copy of the context, so a timed-out function never shares the pooled one,
+// and it is counted as pending until it returns, see Stop.
+func (c *callback) invoke(ctx *Context, args []reflect.Value) (results []reflect.Value, err error) {
+	if _, ok := ctx.Deadline(); !ok {
+		return c.fn.Call(c.fullArgs(ctx, args)), nil
+	}
+	type output struct {
+		results []reflect.Value
+		err     error
+	}
+	dc := ctx.detach()
+	done := make(chan output, 1)
+	atomic.AddInt64(&c.server.pending, 1)
+	go func() {
+		defer atomic.AddInt64(&c.server.pending, -1)
+		defer func() {
+			if p := recover(); p != nil {
+				done <- output{err: c.server.stack(p, c.providerMethod)}
+			}
+		}()
+		done <- output{results: c.fn.Call(c.fullArgs(dc, args))}
+	}()
+	select {
+	case out := <-done:
+		copyStore(&ctx.Store, &dc.Store)
+		return out.results, out.err
+	case <-ctx.Done():
+		//the running function owns the arguments, the complete handlers get a copy
+		ctx.args, _ = c.decoder.decode(ctx.msg.Params)
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, NewError(ErrTimeout, "request timeout")
+		}
+		return nil, NewError(ErrServer, ctx.Err().Error())
+	}
+}
+
+// fullArgs the arguments of the function, with the receiver and the context
+func (c *callback) fullArgs(ctx *Context, args []reflect.Value) []reflect.Value {
+	fullArgs := make([]reflect.Value, 0, 2+len(args))
+	if c.rcv.IsValid() {
+		fullArgs = append(fullArgs, c.rcv)
+	}
+	switch {
+	case c.hasRPCCtx:
+		fullArgs = append(fullArgs, reflect.ValueOf(ctx))
+	case c.hasCtx:
+		fullArgs = append(fullArgs, reflect.ValueOf(ctx.Context))
+	}
+	return append(fullArgs, args...)
+}
+
+// validateArguments validates the struct arguments with validate tags,
+// a failure is ErrBadParams with the error of each field as data.
+func (c *callback) validateArguments(args []reflect.Value) error {
+	opt := c.server.Opt()
+	if c.skipValidate || opt.DisableValidation {
+		return nil
+	}
+	for _, arg := range args {
+		if IndirectType(arg.Type()).Kind() != reflect.Struct || !util.HasValidateTag(arg.Type()) {
+			continue
+		}
+		if arg.Kind() == reflect.Ptr && arg.IsNil() {
+			continue
+		}
+		fields, err := opt.validator().ValidFieldsZh(arg.Interface())
+		if err == nil {
+			continue
+		}
+		if len(fields) == 0 {
+			return NewError(ErrBadParams, err.Error())
+		}
+		return NewError(ErrBadParams, err.Error(), fields)
+	}
+	return nil
+}
+
+// timeout returns the execution time limit of the callback, 0 means no limit.
+// Method timeouts take precedence over namespace timeouts, and Option over the receiver.
+func (c *callback) timeout() time.Duration {
+	timeouts := c.server.Opt().Timeouts
+	if d, ok := timeouts[c.providerMethod]; ok {
+		return d
+	}
+	if c.methodTimeout > 0 {
+		return c.methodTimeout
+	}
+	if elem := strings.SplitN(c.providerMethod, splitMethodSeparator, 2); len(elem) == 2 {
+		if d, ok := timeouts[elem[0]]; ok {
+			return d
+		}
+		if namespace, version := splitVersion(elem[0]); len(version) > 0 {
+			if d, ok := timeouts[namespace]; ok {
+				return d
+			}
+		}
+	}
+	return c.namespaceTimeout
+}
+
 // makeArgTypes ...
 func (c *callback) makeArgTypes() bool {
 	fnt := c.fn.Type()
@@ -125,6 +251,7 @@ func (c *callback) makeArgTypes() bool {
 
 	if fnt.NumIn() > firstArg && fnt.In(firstArg).Implements(contextType) {
 		c.hasCtx = true
+		c.hasRPCCtx = fnt.In(firstArg) == rpcContextType
 		firstArg++
 	}
 	//Add all remaining parameters.
@@ -0,0 +1,262 @@
+package j2rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// BatchElem is an element of a batch request.
+type BatchElem struct {
+	Method string
+	Args   []interface{}
+	//Result the response is unmarshalled into it, may be nil
+	Result interface{}
+	//Error is set if the server returns an error for this element, or the response is missing
+	Error error
+}
+
+// Client is a JSON-RPC client for j2rpc services.
+type Client struct {
+	url        string
+	httpClient *http.Client
+	header     http.Header
+	opt        *Option
+	id         uint64
+	envelope   *clientEnvelope
+}
+
+// clientEnvelope the envelope session of the client
+type clientEnvelope struct {
+	mu        sync.Mutex
+	publicKey string
+	useBase64 bool
+	header    string
+	key       []byte
+}
+
+// BatchCall sends all elements in a single batch request and waits for the responses.
+// The returned error is only about the request itself, errors of each element are set to BatchElem.Error.
+func (c *Client) BatchCall(ctx context.Context, elems []BatchElem) (err error) {
+	if len(elems) == 0 {
+		return
+	}
+	msgs := make([]*RPCMessage, len(elems))
+	byID := make(map[string]int, len(elems))
+	for i, elem := range elems {
+		if msgs[i], err = c.newMessage(elem.Method, elem.Args); err != nil {
+			return
+		}
+		byID[string(msgs[i].ID)] = i
+	}
+	body, err := c.do(ctx, msgs)
+	if err != nil {
+		return
+	}
+	if !isBatchBody(body) {
+		// the whole batch was rejected
+		resp := new(RPCMessage)
+		if err = json.Unmarshal(body, resp); err != nil {
+			return
+		}
+		if resp.Error != nil {
+			return resp.Error
+		}
+		return NewError(ErrInvalidRequest, "non-batch response")
+	}
+	var responses []*RPCMessage
+	if err = json.Unmarshal(body, &responses); err != nil {
+		return
+	}
+	for _, resp := range responses {
+		i, ok := byID[string(resp.ID)]
+		if !ok {
+			continue
+		}
+		delete(byID, string(resp.ID))
+		elems[i].Error = resp.decodeResult(elems[i].Result)
+	}
+	for _, i := range byID {
+		elems[i].Error = NewError(ErrInternal, "missing response")
+	}
+	return
+}
+
+// Call invokes the given method and unmarshalls the result into result, which may be nil.
+func (c *Client) Call(ctx context.Context, method string, result interface{}, args ...interface{}) (err error) {
+	msg, err := c.newMessage(method, args)
+	if err != nil {
+		return
+	}
+	body, err := c.do(ctx, msg)
+	if err != nil {
+		return
+	}
+	resp := new(RPCMessage)
+	if err = json.Unmarshal(body, resp); err != nil {
+		return
+	}
+	return resp.decodeResult(result)
+}
+
+// Notify invokes the given method without id, the server sends no response.
+func (c *Client) Notify(ctx context.Context, method string, args ...interface{}) (err error) {
+	msg, err := c.newMessage(method, args)
+	if err != nil {
+		return
+	}
+	msg.ID = nil
+	_, err = c.do(ctx, msg)
+	return
+}
+
+// SetCryptoKey same format as Option.CryptoKey
+func (c *Client) SetCryptoKey(key string) *Client {
+	c.opt.CryptoKey = key
+	return c
+}
+
+// SetEnvelopeKey enables the envelope encryption with the server's ECIES public key (hex),
+// useBase64 must match EnvelopeOption.UseBase64 of the server
+func (c *Client) SetEnvelopeKey(publicKey string, useBase64 ...bool) *Client {
+	c.envelope = &clientEnvelope{publicKey: publicKey, useBase64: len(useBase64) > 0 && useBase64[0]}
+	return c
+}
+
+// SetHTTPClient ...
+func (c *Client) SetHTTPClient(httpClient *http.Client) *Client {
+	c.httpClient = httpClient
+	return c
+}
+
+// SetHeader sets a header sent with every request
+func (c *Client) SetHeader(key, value string) *Client {
+	c.header.Set(key, value)
+	return c
+}
+
+// do sends val, with a new envelope session if the server rejects the session
+func (c *Client) do(ctx context.Context, val interface{}) (body []byte, err error) {
+	plain, err := json.Marshal(val)
+	if err != nil {
+		return
+	}
+	body, err = c.post(ctx, plain, false)
+	var rpcErr *Error
+	if c.envelope != nil && errors.As(err, &rpcErr) && rpcErr.Code == ErrSession {
+		body, err = c.post(ctx, plain, true)
+	}
+	return
+}
+
+// post ...
+func (c *Client) post(ctx context.Context, plain []byte, renewSession bool) (body []byte, err error) {
+	parse, header, err := c.bodyParser(renewSession)
+	if err != nil {
+		return
+	}
+	if body, err = parse.encrypt(plain); err != nil {
+		return
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	for k, vs := range c.header {
+		req.Header[k] = vs
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if parse != nil {
+		req.Header.Set("Content-Type", "text/plain")
+	}
+	if len(header) > 0 {
+		req.Header.Set(SessionHeader, header)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return
+	}
+	// the errors before the body is decrypted, e.g. of the envelope session, are not encrypted
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+		parse = nil
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNoContent:
+		return nil, nil
+	default:
+		// a rejected request may still carry a JSON-RPC error, e.g. when the server is stopping
+		errMsg := new(RPCMessage)
+		if plain, e := parse.decrypt(bytes.TrimSpace(body)); e == nil &&
+			json.Unmarshal(plain, errMsg) == nil && errMsg.Error != nil {
+			return nil, errMsg.Error
+		}
+		return nil, fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	if body, err = parse.decrypt(bytes.TrimSpace(body)); err != nil {
+		return
+	}
+	// the whole request is rejected by the session
+	if !isBatchBody(body) {
+		errMsg := new(RPCMessage)
+		if json.Unmarshal(body, errMsg) == nil && errMsg.Error != nil && errMsg.Error.Code == ErrSession {
+			return nil, errMsg.Error
+		}
+	}
+	return
+}
+
+// bodyParser returns the bodyParser of the envelope session or the crypto key, and the session header
+func (c *Client) bodyParser(renewSession bool) (parse bodyParser, header string, err error) {
+	if c.envelope == nil {
+		return c.opt.bodyParser(), "", nil
+	}
+	e := c.envelope
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.header) == 0 || renewSession {
+		if e.header, e.key, err = NewEnvelopeSession(e.publicKey); err != nil {
+			return
+		}
+	}
+	sess := &envelopeSession{key: e.key, useBase64: e.useBase64}
+	return sess.parseBody, e.header, nil
+}
+
+// newMessage ...
+func (c *Client) newMessage(method string, args []interface{}) (msg *RPCMessage, err error) {
+	msg = &RPCMessage{
+		ID:      json.RawMessage(strconv.FormatUint(atomic.AddUint64(&c.id, 1), 10)),
+		Version: vsn,
+		Method:  method,
+	}
+	if len(args) > 0 {
+		if msg.Params, err = json.Marshal(args); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// NewClient ...
+func NewClient(url string) *Client {
+	return &Client{
+		url:        url,
+		httpClient: http.DefaultClient,
+		header:     make(http.Header),
+		opt:        &Option{},
+	}
+}
@@ -0,0 +1,226 @@
+package j2rpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/atcharles/glibs/crypto"
+)
+
+type clientService struct {
+	notified atomic.Int64
+}
+
+func (s *clientService) Add(_ context.Context, a, b int) (int, error) { return a + b, nil }
+
+func (s *clientService) Fail(_ context.Context) error { return NewError(ErrForbidden, "denied") }
+
+func (s *clientService) Notice(_ context.Context, n int64) error {
+	s.notified.Add(n)
+	return nil
+}
+
+// newClientServer an httptest server of a RPCServer with clientService registered as calc,
+// the bodies of the requests are passed to inspect
+func newClientServer(t *testing.T, opt *Option, inspect func(r *http.Request, body []byte)) (*httptest.Server, *clientService) {
+	t.Helper()
+	svc := new(clientService)
+	s := New(opt)
+	s.Register(svc, "calc")
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if inspect != nil {
+			body, _ := io.ReadAll(r.Body)
+			inspect(r, body)
+			r.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		s.ServeHTTP(w, r)
+	}))
+	t.Cleanup(ts.Close)
+	return ts, svc
+}
+
+func TestClientCall(t *testing.T) {
+	ts, svc := newClientServer(t, &Option{SnakeNamespace: true}, nil)
+	c := NewClient(ts.URL)
+	ctx := context.Background()
+
+	var sum int
+	require.NoError(t, c.Call(ctx, "calc.add", &sum, 1, 2))
+	assert.Equal(t, 3, sum)
+
+	err := c.Call(ctx, "calc.fail", nil)
+	var rpcErr *Error
+	require.ErrorAs(t, err, &rpcErr)
+	assert.Equal(t, ErrForbidden, rpcErr.Code)
+	assert.Equal(t, "denied", rpcErr.Message)
+
+	require.ErrorAs(t, c.Call(ctx, "calc.none", nil), &rpcErr)
+	assert.Equal(t, ErrNoMethod, rpcErr.Code)
+
+	require.NoError(t, c.Notify(ctx, "calc.notice", 5))
+	assert.Equal(t, int64(5), svc.notified.Load())
+}
+
+func TestClientBatchCall(t *testing.T) {
+	ts, _ := newClientServer(t, &Option{SnakeNamespace: true}, nil)
+	c := NewClient(ts.URL)
+	sums := make([]int, 3)
+	elems := []BatchElem{
+		{Method: "calc.add", Args: []interface{}{1, 1}, Result: &sums[0]},
+		{Method: "calc.fail"},
+		{Method: "calc.add", Args: []interface{}{2, 3}, Result: &sums[2]},
+	}
+	require.NoError(t, c.BatchCall(context.Background(), elems))
+	assert.NoError(t, elems[0].Error)
+	assert.Equal(t, 2, sums[0])
+	var rpcErr *Error
+	require.ErrorAs(t, elems[1].Error, &rpcErr)
+	assert.Equal(t, ErrForbidden, rpcErr.Code)
+	assert.NoError(t, elems[2].Error)
+	assert.Equal(t, 5, sums[2])
+}
+
+func TestClientBatchResponses(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		status   int
+		// want the results of the elements, nil for an error
+		want    []interface{}
+		wantErr bool
+	}{
+		{
+			name:     "out of order",
+			response: `[{"jsonrpc":"2.0","id":2,"result":"b"},{"jsonrpc":"2.0","id":1,"result":"a"}]`,
+			want:     []interface{}{"a", "b"},
+		},
+		{
+			name:     "missing",
+			response: `[{"jsonrpc":"2.0","id":2,"result":"b"}]`,
+			want:     []interface{}{nil, "b"},
+		},
+		{
+			name:     "unknown id",
+			response: `[{"jsonrpc":"2.0","id":9,"result":"x"},{"jsonrpc":"2.0","id":1,"result":"a"}]`,
+			want:     []interface{}{"a", nil},
+		},
+		{
+			name:     "rejected",
+			response: `{"jsonrpc":"2.0","id":null,"error":{"code":-32600,"message":"batch too large"}}`,
+			wantErr:  true,
+		},
+		{
+			name:     "unavailable",
+			response: `{"jsonrpc":"2.0","id":null,"error":{"code":-32000,"message":"server is stopping"}}`,
+			status:   http.StatusServiceUnavailable,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				if tt.status > 0 {
+					w.WriteHeader(tt.status)
+				}
+				_, _ = w.Write([]byte(tt.response))
+			}))
+			defer ts.Close()
+			results := make([]string, 2)
+			elems := []BatchElem{{Method: "a", Result: &results[0]}, {Method: "b", Result: &results[1]}}
+			err := NewClient(ts.URL).BatchCall(context.Background(), elems)
+			if tt.wantErr {
+				var rpcErr *Error
+				require.ErrorAs(t, err, &rpcErr)
+				return
+			}
+			require.NoError(t, err)
+			for i, want := range tt.want {
+				if want == nil {
+					assert.Error(t, elems[i].Error, i)
+					continue
+				}
+				assert.NoError(t, elems[i].Error, i)
+				assert.Equal(t, want, results[i], i)
+			}
+		})
+	}
+}
+
+func TestClientHTTPError(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		response string
+		code     ErrorCode
+	}{
+		{"rpc error", http.StatusServiceUnavailable, `{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"server is stopping"}}`, ErrServer},
+		{"plain text", http.StatusBadGateway, "bad gateway", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.response))
+			}))
+			defer ts.Close()
+			err := NewClient(ts.URL).Call(context.Background(), "a", nil)
+			require.Error(t, err)
+			var rpcErr *Error
+			if tt.code == 0 {
+				assert.False(t, errors.As(err, &rpcErr))
+				assert.Contains(t, err.Error(), tt.response)
+				return
+			}
+			require.ErrorAs(t, err, &rpcErr)
+			assert.Equal(t, tt.code, rpcErr.Code)
+		})
+	}
+}
+
+func TestClientCryptoKey(t *testing.T) {
+	const key = "0123456789abcdef"
+	var plain atomic.Bool
+	ts, _ := newClientServer(t, &Option{SnakeNamespace: true, CryptoKey: key}, func(r *http.Request, body []byte) {
+		if bytes.Contains(body, []byte("calc.add")) {
+			plain.Store(true)
+		}
+	})
+	var sum int
+	require.NoError(t, NewClient(ts.URL).SetCryptoKey(key).Call(context.Background(), "calc.add", &sum, 2, 2))
+	assert.Equal(t, 4, sum)
+	assert.False(t, plain.Load(), "the request is sent in plain text")
+
+	assert.Error(t, NewClient(ts.URL).Call(context.Background(), "calc.add", &sum, 2, 2))
+}
+
+func TestClientEnvelopeSessionRetry(t *testing.T) {
+	pair, err := crypto.EEInc.GenerateKeyPair()
+	require.NoError(t, err)
+	var requests atomic.Int64
+	ts, _ := newClientServer(t, &Option{SnakeNamespace: true, Envelope: &EnvelopeOption{PrivateKey: pair.PrivateKey}},
+		func(r *http.Request, body []byte) { requests.Add(1) })
+	c := NewClient(ts.URL).SetEnvelopeKey(pair.PublicKey)
+	ctx := context.Background()
+
+	var sum int
+	require.NoError(t, c.Call(ctx, "calc.add", &sum, 3, 4))
+	assert.Equal(t, 7, sum)
+	assert.Equal(t, int64(1), requests.Load())
+
+	// the server rejects the session, the client makes a new one and sends the request again
+	c.envelope.header = "invalid"
+	require.NoError(t, c.Call(ctx, "calc.add", &sum, 5, 6))
+	assert.Equal(t, 11, sum)
+	assert.Equal(t, int64(3), requests.Load())
+	assert.NotEqual(t, "invalid", c.envelope.header)
+}
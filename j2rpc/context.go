@@ -1,8 +1,11 @@
 package j2rpc
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"net/http"
+	"reflect"
 	"sync"
 )
 
@@ -21,6 +24,19 @@ type Context struct {
 	currentIndex int
 	msg          *RPCMessage
 	midHandlers  Handlers
+	args         []reflect.Value
+	//meta the metadata of the called method
+	meta *MethodMeta
+	//parseBody encrypts the response as the request was encrypted, nil is plain text
+	parseBody bodyParser
+	//buf the pooled buffer holding Body, put back when the context is released
+	buf *bytes.Buffer
+	//fields the sparse fieldset of FieldsHeader
+	fields Fields
+	//result the value returned by the method, it's encoded to the response directly
+	result interface{}
+	//subCtx the context of the subscription, cancelled when it is unsubscribed or the connection is closed
+	subCtx context.Context
 
 	Body  []byte
 	Store sync.Map
@@ -28,12 +44,19 @@ type Context struct {
 
 func (c *Context) App() RPCServer { return c.app }
 
+// Args returns the parsed arguments of the method (without context), nil if the params were not parsed
+func (c *Context) Args() []reflect.Value { return c.args }
+
 // BeginRequest ......
 func (c *Context) BeginRequest(w http.ResponseWriter, r *http.Request) *Context {
 	c.w = w
 	c.r = r
 	c.isStopped = false
 	c.currentIndex = 0
+	c.subCtx = nil
+	c.parseBody = nil
+	c.meta = nil
+	c.fields = nil
 	return c
 }
 
@@ -79,6 +102,17 @@ func (c *Context) Release() {
 // Request ......
 func (c *Context) Request() *http.Request { return c.r }
 
+// Result returns the value returned by the method, or the encoded result as json.RawMessage, nil if there is none
+func (c *Context) Result() interface{} {
+	if c.result != nil {
+		return c.result
+	}
+	if len(c.msg.Result) > 0 {
+		return c.msg.Result
+	}
+	return nil
+}
+
 // SetContext ......
 func (c *Context) SetContext(ctx context.Context) *Context {
 	c.Context = ctx
@@ -93,6 +127,39 @@ func (c *Context) Stop() { c.isStopped = true }
 // Writer ......
 func (c *Context) Writer() http.ResponseWriter { return c.w }
 
+// detach returns a copy of the context which shares no pooled memory, for a method which may outlive the request.
+// The Store is copied, the args are shared.
+func (c *Context) detach() *Context {
+	dc := &Context{
+		Context:   c.Context,
+		app:       c.app,
+		w:         c.w,
+		r:         c.r,
+		msg:       &RPCMessage{},
+		args:      c.args,
+		meta:      c.meta,
+		parseBody: c.parseBody,
+		fields:    c.fields,
+		Body:      append([]byte(nil), c.Body...),
+	}
+	if c.msg != nil {
+		*dc.msg = *c.msg
+		dc.msg.ID = append(json.RawMessage(nil), c.msg.ID...)
+		dc.msg.Params = append(json.RawMessage(nil), c.msg.Params...)
+		dc.msg.Result, dc.msg.Error = nil, nil
+	}
+	copyStore(&dc.Store, &c.Store)
+	return dc
+}
+
+// copyStore stores the values of src in dst
+func copyStore(dst, src *sync.Map) {
+	src.Range(func(key, value interface{}) bool {
+		dst.Store(key, value)
+		return true
+	})
+}
+
 // newContext ......
 func newContext(app *server) *Context {
 	return &Context{
@@ -0,0 +1,196 @@
+package j2rpc
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/atcharles/glibs/crypto"
+)
+
+const (
+	// SessionHeader the header of the envelope session, the EnvelopeSession encrypted with the server's public key
+	SessionHeader = "X-J2rpc-Session"
+	// SessionQuery the query parameter of the envelope session, for the websocket connections of browsers
+	SessionQuery = "j2rpc_session"
+
+	defaultSessionTTL  = time.Hour * 24
+	defaultMaxSessions = 10000
+	// sessionClockSkew tolerance of the creation time of the sessions made by clients ahead of the server
+	sessionClockSkew = time.Minute * 5
+)
+
+type (
+	// EnvelopeOption enables the envelope encryption, which takes the place of CryptoKey.
+	// The client encrypts a random AES session key with the server's ECIES public key and sends it with every
+	// request in SessionHeader, the request and response bodies are encrypted and authenticated with AES-GCM
+	// by the session key. A client gets ErrSession when its session is expired or invalid, then makes a new one.
+	EnvelopeOption struct {
+		//PrivateKey hex ECIES private key of the server, see crypto.EEInc.GenerateKeyPair
+		PrivateKey string
+		//SessionTTL lifetime of a session from its creation, default is 24h
+		SessionTTL time.Duration
+		//MaxSessions max number of decrypted sessions kept in memory, default is 10000
+		MaxSessions int
+		//UseBase64 the bodies are encoded in base64 (raw url), default is upper case hex
+		UseBase64 bool
+	}
+
+	// EnvelopeSession the plaintext of SessionHeader
+	EnvelopeSession struct {
+		//Key hex of the AES key, 16, 24 or 32 bytes
+		Key string `json:"key"`
+		//CreatedAt unix seconds
+		CreatedAt int64 `json:"created_at"`
+	}
+
+	// bodyParser encrypts or decrypts the bodies of a request and its response, nil is plain text
+	bodyParser func(body []byte, isDecrypt bool) ([]byte, error)
+
+	// envelopeCache the decrypted sessions, key is the sha256 of SessionHeader
+	envelopeCache struct {
+		mu       sync.Mutex
+		sessions map[string]*envelopeSession
+	}
+
+	envelopeSession struct {
+		key       []byte
+		expireAt  time.Time
+		useBase64 bool
+	}
+)
+
+// decrypt ...
+func (p bodyParser) decrypt(body []byte) ([]byte, error) {
+	if p == nil {
+		return body, nil
+	}
+	return p(body, true)
+}
+
+// encrypt ...
+func (p bodyParser) encrypt(body []byte) ([]byte, error) {
+	if p == nil {
+		return body, nil
+	}
+	return p(body, false)
+}
+
+// get ...
+func (e *envelopeCache) get(id string) *envelopeSession {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.sessions[id]
+}
+
+// put removes the expired sessions when the cache is full, then an arbitrary one if it's still full
+func (e *envelopeCache) put(id string, sess *envelopeSession, limit int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.sessions == nil {
+		e.sessions = make(map[string]*envelopeSession)
+	}
+	if len(e.sessions) >= limit {
+		now := time.Now()
+		for k, v := range e.sessions {
+			if now.After(v.expireAt) {
+				delete(e.sessions, k)
+			}
+		}
+	}
+	for k := range e.sessions {
+		if len(e.sessions) < limit {
+			break
+		}
+		delete(e.sessions, k)
+	}
+	e.sessions[id] = sess
+}
+
+// parseBody the bodyParser of the session
+func (e *envelopeSession) parseBody(body []byte, isDecrypt bool) ([]byte, error) {
+	if len(body) == 0 {
+		return body, nil
+	}
+	if isDecrypt {
+		return crypto.AESGCMDecrypt(e.key, body, nil, e.useBase64)
+	}
+	return crypto.AESGCMEncrypt(e.key, body, nil, e.useBase64)
+}
+
+// NewEnvelopeSession makes a session with a random AES-256 key, and returns the value of SessionHeader
+func NewEnvelopeSession(publicKey string) (header string, key []byte, err error) {
+	key = make([]byte, 32)
+	if _, err = rand.Read(key); err != nil {
+		return
+	}
+	bts, err := json.Marshal(&EnvelopeSession{Key: hex.EncodeToString(key), CreatedAt: time.Now().Unix()})
+	if err != nil {
+		return
+	}
+	header, err = crypto.EEInc.Encrypt(string(bts), publicKey)
+	return
+}
+
+// envelopeSession returns the session of the header, the header is decrypted once until it's evicted
+func (s *server) envelopeSession(opt *EnvelopeOption, header string) (*envelopeSession, error) {
+	if len(header) == 0 {
+		return nil, NewError(ErrSession, "session is required")
+	}
+	sum := sha256.Sum256([]byte(header))
+	id := hex.EncodeToString(sum[:])
+	sess := s.envelopes.get(id)
+	if sess == nil {
+		plain, err := crypto.EEInc.Decrypt(header, opt.PrivateKey)
+		if err != nil {
+			return nil, NewError(ErrSession, "invalid session")
+		}
+		var es EnvelopeSession
+		if err = json.Unmarshal([]byte(plain), &es); err != nil {
+			return nil, NewError(ErrSession, "invalid session")
+		}
+		key, err := hex.DecodeString(es.Key)
+		if err != nil || (len(key) != 16 && len(key) != 24 && len(key) != 32) {
+			return nil, NewError(ErrSession, "invalid session key")
+		}
+		createdAt := time.Unix(es.CreatedAt, 0)
+		if createdAt.After(time.Now().Add(sessionClockSkew)) {
+			return nil, NewError(ErrSession, "invalid session time")
+		}
+		ttl, limit := opt.SessionTTL, opt.MaxSessions
+		if ttl <= 0 {
+			ttl = defaultSessionTTL
+		}
+		if limit <= 0 {
+			limit = defaultMaxSessions
+		}
+		sess = &envelopeSession{key: key, expireAt: createdAt.Add(ttl), useBase64: opt.UseBase64}
+		s.envelopes.put(id, sess, limit)
+	}
+	if time.Now().After(sess.expireAt) {
+		return nil, NewError(ErrSession, "session expired")
+	}
+	return sess, nil
+}
+
+// requestBodyParser returns the bodyParser of the request: the envelope session if EnvelopeOption is set,
+// otherwise the CryptoKey, or nil
+func (s *server) requestBodyParser(r *http.Request) (bodyParser, error) {
+	opt := s.Opt().Envelope
+	if opt == nil {
+		return s.Opt().bodyParser(), nil
+	}
+	header := r.Header.Get(SessionHeader)
+	if len(header) == 0 {
+		header = r.URL.Query().Get(SessionQuery)
+	}
+	sess, err := s.envelopeSession(opt, header)
+	if err != nil {
+		return nil, err
+	}
+	return sess.parseBody, nil
+}
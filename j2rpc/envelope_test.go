@@ -0,0 +1,109 @@
+package j2rpc
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/atcharles/glibs/crypto"
+)
+
+func TestEnvelopeSession(t *testing.T) {
+	pair, err := crypto.EEInc.GenerateKeyPair()
+	require.NoError(t, err)
+	opt := &EnvelopeOption{PrivateKey: pair.PrivateKey, SessionTTL: time.Hour}
+	s := New(&Option{SnakeNamespace: true, Envelope: opt}).(*server)
+	s.Register(new(clientService), "calc")
+	header := func(key string, createdAt time.Time) string {
+		bts, err := json.Marshal(&EnvelopeSession{Key: key, CreatedAt: createdAt.Unix()})
+		require.NoError(t, err)
+		h, err := crypto.EEInc.Encrypt(string(bts), pair.PublicKey)
+		require.NoError(t, err)
+		return h
+	}
+	key := strings.Repeat("ab", 32)
+	tests := []struct {
+		name   string
+		header string
+		// err the message of ErrSession, empty if the session is valid
+		err string
+	}{
+		{"valid", header(key, time.Now()), ""},
+		{"clock skew", header(key, time.Now().Add(time.Minute)), ""},
+		{"required", "", "session is required"},
+		{"invalid", "invalid", "invalid session"},
+		{"invalid key", header("abcd", time.Now()), "invalid session key"},
+		{"future", header(key, time.Now().Add(time.Hour)), "invalid session time"},
+		{"expired", header(key, time.Now().Add(-2*time.Hour)), "session expired"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sess, err := s.envelopeSession(opt, tt.header)
+			if len(tt.err) == 0 {
+				require.NoError(t, err)
+				assert.Equal(t, strings.Repeat("\xab", 32), string(sess.key))
+				return
+			}
+			require.Error(t, err)
+			e, ok := err.(*Error)
+			require.True(t, ok)
+			assert.Equal(t, ErrSession, e.Code)
+			assert.Equal(t, tt.err, e.Message)
+		})
+	}
+
+	// a cached session expires as well
+	h := header(key, time.Now())
+	_, err = s.envelopeSession(opt, h)
+	require.NoError(t, err)
+	sum := sha256.Sum256([]byte(h))
+	cached := s.envelopes.get(hex.EncodeToString(sum[:]))
+	require.NotNil(t, cached)
+	cached.expireAt = time.Now().Add(-time.Second)
+	_, err = s.envelopeSession(opt, h)
+	require.Error(t, err)
+	assert.Equal(t, "session expired", err.(*Error).Message)
+
+	// the request and the response are encrypted by the session key
+	plainKey := []byte(strings.Repeat("\xab", 32))
+	body, err := crypto.AESGCMEncrypt(plainKey, []byte(`{"jsonrpc":"2.0","id":1,"method":"calc.add","params":[1,2]}`), nil)
+	require.NoError(t, err)
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(string(body)))
+	r.Header.Set(SessionHeader, header(key, time.Now()))
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+	out, err := crypto.AESGCMDecrypt(plainKey, w.Body.Bytes(), nil)
+	require.NoError(t, err, w.Body.String())
+	msg := new(RPCMessage)
+	require.NoError(t, json.Unmarshal(out, msg))
+	assert.Equal(t, "3", string(msg.Result))
+}
+
+func TestEnvelopeCacheEviction(t *testing.T) {
+	var cache envelopeCache
+	valid := func() *envelopeSession { return &envelopeSession{expireAt: time.Now().Add(time.Hour)} }
+	cache.put("a", valid(), 3)
+	cache.put("expired", &envelopeSession{expireAt: time.Now().Add(-time.Second)}, 3)
+	cache.put("b", valid(), 3)
+
+	// the expired session is evicted first
+	cache.put("c", valid(), 3)
+	assert.Nil(t, cache.get("expired"))
+	for _, id := range []string{"a", "b", "c"} {
+		assert.NotNil(t, cache.get(id), id)
+	}
+
+	// then any one of them when they are all valid
+	cache.put("d", valid(), 3)
+	assert.NotNil(t, cache.get("d"))
+	assert.Len(t, cache.sessions, 3)
+}
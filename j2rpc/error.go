@@ -8,9 +8,12 @@ const (
 	ErrBadParams      ErrorCode = -32602
 	ErrInternal       ErrorCode = -32603
 	ErrServer         ErrorCode = -32000
+	ErrTimeout        ErrorCode = -32001
+	ErrSession        ErrorCode = -32002
 
-	ErrAuthorization ErrorCode = 401
-	ErrForbidden     ErrorCode = 403
+	ErrAuthorization   ErrorCode = 401
+	ErrForbidden       ErrorCode = 403
+	ErrTooManyRequests ErrorCode = 429
 )
 
 // Error ... Error codes
@@ -0,0 +1,167 @@
+package j2rpc
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/go-playground/validator/v10"
+)
+
+var (
+	// DefaultErrorRegistry used by the servers without their own ErrorRegistry
+	DefaultErrorRegistry = NewErrorRegistry()
+
+	defaultErrorLogger = NewLevelLogger("[STDOUT]")
+)
+
+// ErrorMapper maps err to the error sent to the client, returns nil if err isn't handled
+type ErrorMapper func(err error) *Error
+
+// ErrorRegistry maps the errors returned by methods to JSON-RPC errors
+type ErrorRegistry struct {
+	mu      sync.RWMutex
+	mappers []ErrorMapper
+	//production hides the messages of unmapped errors behind a correlation id
+	production bool
+	logger     LevelLogger
+}
+
+// Map returns the JSON-RPC error of err, nil if err is nil.
+// The j2rpc error types take precedence, then the registered mappers in order,
+// otherwise err becomes ErrInternal. In production mode ErrInternal messages are hidden.
+func (r *ErrorRegistry) Map(err error) *Error {
+	if err == nil {
+		return nil
+	}
+	r.mu.RLock()
+	mappers, production := r.mappers, r.production
+	r.mu.RUnlock()
+	e := mapBuiltinError(err)
+	for i := 0; e == nil && i < len(mappers); i++ {
+		e = mappers[i](err)
+	}
+	if e != nil && (!production || e.Code != ErrInternal) {
+		return e
+	}
+	if !production {
+		return NewError(ErrInternal, err.Error())
+	}
+	errorID := newErrorID()
+	r.Logger().Errorf("[Error-ID:%s] %s", errorID, ErrorChain(err))
+	return NewError(ErrInternal, "internal error", map[string]string{"error_id": errorID})
+}
+
+// Register appends the mapper
+func (r *ErrorRegistry) Register(mapper ErrorMapper) *ErrorRegistry {
+	r.mu.Lock()
+	r.mappers = append(r.mappers, mapper)
+	r.mu.Unlock()
+	return r
+}
+
+// RegisterIs maps the errors matching target by errors.Is,
+// e.g. RegisterIs(gorm.ErrRecordNotFound, 404, "record not found")
+func (r *ErrorRegistry) RegisterIs(target error, code ErrorCode, msg string) *ErrorRegistry {
+	return r.Register(func(err error) *Error {
+		if !errors.Is(err, target) {
+			return nil
+		}
+		return NewError(code, msg)
+	})
+}
+
+// Logger ...
+func (r *ErrorRegistry) Logger() LevelLogger {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.logger == nil {
+		return defaultErrorLogger
+	}
+	return r.logger
+}
+
+// SetLogger sets the logger of the hidden errors in production mode
+func (r *ErrorRegistry) SetLogger(logger LevelLogger) *ErrorRegistry {
+	r.mu.Lock()
+	r.logger = logger
+	r.mu.Unlock()
+	return r
+}
+
+// SetProduction enables or disables the production mode
+func (r *ErrorRegistry) SetProduction(production bool) *ErrorRegistry {
+	r.mu.Lock()
+	r.production = production
+	r.mu.Unlock()
+	return r
+}
+
+// ErrorChain formats err and the errors it wraps
+func ErrorChain(err error) string {
+	chain := make([]string, 0)
+	for ; err != nil; err = errors.Unwrap(err) {
+		chain = append(chain, fmt.Sprintf("(%T) %s", err, err.Error()))
+	}
+	return strings.Join(chain, " <- ")
+}
+
+// NewErrorRegistry returns a registry with the mapper of validator errors
+func NewErrorRegistry() *ErrorRegistry {
+	return (&ErrorRegistry{}).Register(ValidationErrorMapper)
+}
+
+// RegisterErrorAs maps the errors matching T by errors.As
+func RegisterErrorAs[T error](r *ErrorRegistry, fn func(err T) *Error) *ErrorRegistry {
+	return r.Register(func(err error) *Error {
+		var target T
+		if !errors.As(err, &target) {
+			return nil
+		}
+		return fn(target)
+	})
+}
+
+// ValidationErrorMapper maps the validator errors to ErrBadParams, Data is the error of each field
+func ValidationErrorMapper(err error) *Error {
+	var ves validator.ValidationErrors
+	if !errors.As(err, &ves) {
+		return nil
+	}
+	fields := make(map[string]string, len(ves))
+	for _, fe := range ves {
+		fields[fe.Field()] = fe.Error()
+	}
+	return NewError(ErrBadParams, "invalid params", fields)
+}
+
+// mapBuiltinError ...
+func mapBuiltinError(err error) *Error {
+	var (
+		rpcErr       IRPCError
+		j2rpcErr     ItfJ2rpcError
+		tokenErr     TokenError
+		forbiddenErr ForbiddenError
+	)
+	switch {
+	case errors.As(err, &j2rpcErr):
+		return NewError(ErrorCode(j2rpcErr.ErrorCode()), j2rpcErr.Error(), j2rpcErr.ErrorData())
+	case errors.As(err, &rpcErr):
+		return rpcErr.RPCError()
+	case errors.As(err, &tokenErr):
+		return NewError(ErrAuthorization, tokenErr.Error())
+	case errors.As(err, &forbiddenErr):
+		return NewError(ErrForbidden, forbiddenErr.Error())
+	}
+	return nil
+}
+
+// newErrorID ...
+func newErrorID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
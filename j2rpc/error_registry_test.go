@@ -0,0 +1,93 @@
+package j2rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type (
+	registryErr struct{ status int }
+
+	registryService struct{ err error }
+)
+
+func (s registryService) Get(_ context.Context) error { return s.err }
+
+func (e *registryErr) Error() string { return fmt.Sprintf("status %d", e.status) }
+
+func TestErrorRegistryMap(t *testing.T) {
+	errNotFound := errors.New("record not found")
+	errBoom := errors.New("boom")
+	r := NewErrorRegistry().
+		RegisterIs(errNotFound, 404, "not found").
+		Register(func(err error) *Error {
+			if errors.Is(err, errBoom) {
+				return NewError(ErrInternal, "boom")
+			}
+			return nil
+		})
+	RegisterErrorAs(r, func(err *registryErr) *Error { return NewError(ErrorCode(err.status), "custom") })
+	validationErr := validator.New().Struct(struct {
+		Name string `validate:"required"`
+	}{})
+	require.Error(t, validationErr)
+
+	tests := []struct {
+		name string
+		err  error
+		// want the code and message, or no error if code is 0
+		code ErrorCode
+		msg  string
+	}{
+		{"nil", nil, 0, ""},
+		{"rpc error", NewError(ErrForbidden, "denied"), ErrForbidden, "denied"},
+		{"wrapped rpc error", fmt.Errorf("call: %w", NewError(ErrForbidden, "denied")), ErrForbidden, "denied"},
+		{"registered is", fmt.Errorf("find: %w", errNotFound), 404, "not found"},
+		{"registered as", fmt.Errorf("api: %w", &registryErr{status: 502}), 502, "custom"},
+		{"validation", validationErr, ErrBadParams, "invalid params"},
+		{"mapped internal", errBoom, ErrInternal, "boom"},
+		{"unmapped", errors.New("db down"), ErrInternal, "db down"},
+	}
+	for _, production := range []bool{false, true} {
+		r.SetProduction(production).SetLogger(NewLevelLogger("[TEST]"))
+		for _, tt := range tests {
+			t.Run(fmt.Sprintf("%s production %v", tt.name, production), func(t *testing.T) {
+				e := r.Map(tt.err)
+				if tt.code == 0 {
+					assert.Nil(t, e)
+					return
+				}
+				require.NotNil(t, e)
+				assert.Equal(t, tt.code, e.Code)
+				if production && tt.code == ErrInternal {
+					// the message is hidden behind the id in the log
+					assert.Equal(t, "internal error", e.Message)
+					data, ok := e.Data.(map[string]string)
+					require.True(t, ok)
+					assert.Len(t, data["error_id"], 16)
+					return
+				}
+				assert.Equal(t, tt.msg, e.Message)
+			})
+		}
+	}
+	fields, ok := r.Map(validationErr).Data.(map[string]string)
+	require.True(t, ok)
+	assert.Contains(t, fields, "Name")
+}
+
+func TestServerErrorRegistry(t *testing.T) {
+	errNotFound := errors.New("record not found")
+	s := New(&Option{SnakeNamespace: true, ErrorRegistry: NewErrorRegistry().RegisterIs(errNotFound, 404, "not found")})
+	s.Register(registryService{err: fmt.Errorf("get: %w", errNotFound)}, "r")
+	_, e := s.Invoke(context.Background(), "r.get", nil)
+	require.NotNil(t, e)
+	assert.Equal(t, ErrorCode(404), e.Code)
+	assert.Equal(t, "not found", e.Message)
+}
@@ -0,0 +1,62 @@
+package j2rpc
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/atcharles/glibs/util"
+)
+
+// FieldsHeader the header of the sparse fieldset, the comma separated paths which the results are projected to,
+// the "fields" member of a message takes precedence over it
+const FieldsHeader = "X-J2rpc-Fields"
+
+// Fields the paths of the sparse fieldset, see util.ProjectJSON.
+// It's a JSON array of strings, or a comma separated string.
+type Fields []string
+
+// UnmarshalJSON ...
+func (f *Fields) UnmarshalJSON(data []byte) error {
+	var paths string
+	if json.Unmarshal(data, &paths) == nil {
+		*f = ParseFields(paths)
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(f))
+}
+
+// ParseFields parses the comma separated paths
+func ParseFields(paths string) Fields {
+	var fields Fields
+	for _, path := range strings.Split(paths, ",") {
+		if path = strings.TrimSpace(path); len(path) > 0 {
+			fields = append(fields, path)
+		}
+	}
+	return fields
+}
+
+// Project projects the marshalled result to the fields, data is returned if there are no fields
+func (f Fields) Project(data []byte) []byte {
+	if len(f) == 0 || len(data) == 0 {
+		return data
+	}
+	return util.ProjectJSON(data, f...)
+}
+
+// Fields returns the sparse fieldset of the call, the "fields" member of the message or FieldsHeader
+func (c *Context) Fields() Fields {
+	if len(c.msg.Fields) > 0 {
+		return c.msg.Fields
+	}
+	return c.fields
+}
+
+// requestFields the fields of FieldsHeader, the in-process calls have none
+func requestFields(r *http.Request) Fields {
+	if r == nil {
+		return nil
+	}
+	return ParseFields(r.Header.Get(FieldsHeader))
+}
@@ -0,0 +1,104 @@
+package j2rpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type (
+	fieldsService struct{}
+
+	fieldsUser struct {
+		ID      int            `json:"id"`
+		Name    string         `json:"name"`
+		Profile map[string]int `json:"profile"`
+		Tags    []fieldsTag    `json:"tags"`
+	}
+
+	fieldsTag struct {
+		ID    int    `json:"id"`
+		Label string `json:"label"`
+	}
+)
+
+func (s *fieldsService) Get(_ context.Context) (*fieldsUser, error) {
+	return &fieldsUser{
+		ID:      1,
+		Name:    "alice",
+		Profile: map[string]int{"age": 30, "level": 2},
+		Tags:    []fieldsTag{{1, "a"}, {2, "b"}},
+	}, nil
+}
+
+// Fields returns the fieldset of the call
+func (s *fieldsService) Fields(c *Context) (Fields, error) { return c.Fields(), nil }
+
+func TestFields(t *testing.T) {
+	s := New(&Option{SnakeNamespace: true})
+	s.Register(new(fieldsService), "user")
+	call := func(fields string) string {
+		if len(fields) > 0 {
+			fields = `,"fields":` + fields
+		}
+		return `{"jsonrpc":"2.0","id":1,"method":"user.get","params":[]` + fields + `}`
+	}
+	full := `{"id":1,"name":"alice","profile":{"age":30,"level":2},"tags":[{"id":1,"label":"a"},{"id":2,"label":"b"}]}`
+	tests := []struct {
+		name   string
+		body   string
+		header string
+		want   string
+	}{
+		{"all", call(""), "", full},
+		{"array", call(`["id","name"]`), "", `{"id":1,"name":"alice"}`},
+		{"string", call(`"id, name"`), "", `{"id":1,"name":"alice"}`},
+		{"nested", call(`["profile.age"]`), "", `{"profile":{"age":30}}`},
+		{"list", call(`["tags.label"]`), "", `{"tags":[{"label":"a"},{"label":"b"}]}`},
+		{"parent and child", call(`["profile","profile.age"]`), "", `{"profile":{"age":30,"level":2}}`},
+		{"not found", call(`["id","none"]`), "", `{"id":1}`},
+		{"header", call(""), "name", `{"name":"alice"}`},
+		{"member over header", call(`["id"]`), "name", `{"id":1}`},
+		{"batch header", "[" + call("") + "," + call(`["id"]`) + "]", "name", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			if len(tt.header) > 0 {
+				r.Header.Set(FieldsHeader, tt.header)
+			}
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, r)
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+			if strings.HasPrefix(tt.body, "[") {
+				var msgs []*RPCMessage
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &msgs))
+				require.Len(t, msgs, 2)
+				assert.JSONEq(t, `{"name":"alice"}`, string(msgs[0].Result))
+				assert.JSONEq(t, `{"id":1}`, string(msgs[1].Result))
+				return
+			}
+			msg := new(RPCMessage)
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), msg))
+			require.Nil(t, msg.Error)
+			assert.JSONEq(t, tt.want, string(msg.Result))
+		})
+	}
+
+	// the method sees the fieldset
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/",
+		strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"user.fields","params":[],"fields":"a,b"}`)))
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":1,"result":["a","b"]}`, w.Body.String())
+
+	// the in-process calls are never projected
+	result, rpcErr := s.Invoke(context.Background(), "user.get", nil)
+	require.Nil(t, rpcErr)
+	assert.JSONEq(t, full, string(result))
+}
@@ -107,6 +107,24 @@ func JsMarshalIndent(val interface{}) (bts []byte) {
 	return
 }
 
+// ParseArguments parses the given args like ParsePositionalArguments, and also accepts a JSON object.
+// The object is mapped to the arguments by names, or decoded into the only argument
+// when names is empty and the argument is a struct. Missing arguments are returned as zero values.
+func ParseArguments(rawArgs json.RawMessage, types []reflect.Type, names []string) ([]reflect.Value, error) {
+	trimmed := bytes.TrimLeft(rawArgs, " \t\r\n")
+	if len(trimmed) == 0 || trimmed[0] != '{' {
+		return ParsePositionalArguments(rawArgs, types)
+	}
+	args, err := parseArgumentObject(trimmed, types, names)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(args); i < len(types); i++ {
+		args = append(args, zeroArgument(types[i]))
+	}
+	return args, nil
+}
+
 // ParsePositionalArguments tries to parse the given args to an array of values with the
 // given types. It returns the parsed values or an error when the args could not be
 // parsed. Missing optional arguments are returned as reflect.Zero|reflect.New values.
@@ -130,13 +148,7 @@ func ParsePositionalArguments(rawArgs json.RawMessage, types []reflect.Type) ([]
 	}
 	// Set any missing args to nil.
 	for i := len(args); i < len(types); i++ {
-		ctp := types[i]
-		addVal := reflect.Zero(ctp)
-		if ctp.Kind() == reflect.Ptr {
-			//addVal = reflect.New(ctp).Elem()
-			addVal = reflect.New(ctp.Elem())
-		}
-		args = append(args, addVal)
+		args = append(args, zeroArgument(types[i]))
 	}
 	return args, nil
 }
@@ -185,6 +197,20 @@ func StringToBytes(s string) []byte {
 	return *(*[]byte)(unsafe.Pointer(&bp))
 }
 
+// isBatchBody reports whether the body is a JSON array (batch request)
+func isBatchBody(body []byte) bool {
+	body = bytes.TrimLeft(body, " \t\r\n")
+	return len(body) > 0 && body[0] == '['
+}
+
+// zeroArgument returns the value of a missing argument, pointers are allocated
+func zeroArgument(typ reflect.Type) reflect.Value {
+	if typ.Kind() == reflect.Ptr {
+		return reflect.New(typ.Elem())
+	}
+	return reflect.Zero(typ)
+}
+
 func isErrorType(t reflect.Type) bool {
 	for t.Kind() == reflect.Ptr {
 		t = t.Elem()
@@ -212,6 +238,41 @@ func parseArgumentArray(dec *json.Decoder, types []reflect.Type) ([]reflect.Valu
 	return args, err
 }
 
+func parseArgumentObject(rawArgs json.RawMessage, types []reflect.Type, names []string) ([]reflect.Value, error) {
+	if len(names) == 0 {
+		if len(types) != 1 || IndirectType(types[0]).Kind() != reflect.Struct {
+			return nil, errors.New("non-array args")
+		}
+		agv := reflect.New(types[0])
+		if err := json.Unmarshal(rawArgs, agv.Interface()); err != nil {
+			return nil, fmt.Errorf("invalid argument 0: %s", err.Error())
+		}
+		return []reflect.Value{agv.Elem()}, nil
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(rawArgs, &fields); err != nil {
+		return nil, err
+	}
+	args := make([]reflect.Value, 0, len(types))
+	for i := 0; i < len(types) && i < len(names); i++ {
+		raw, ok := fields[names[i]]
+		if !ok {
+			args = append(args, zeroArgument(types[i]))
+			continue
+		}
+		agv := reflect.New(types[i])
+		if err := json.Unmarshal(raw, agv.Interface()); err != nil {
+			return args, fmt.Errorf("invalid argument %s: %s", names[i], err.Error())
+		}
+		if agv.Elem().Kind() == reflect.Ptr && agv.Elem().IsNil() {
+			args = append(args, zeroArgument(types[i]))
+			continue
+		}
+		args = append(args, agv.Elem())
+	}
+	return args, nil
+}
+
 // validateRequest returns a non-zero response code and error message if the
 // request is invalid.
 func validateRequest(r *http.Request) (int, error) {
@@ -238,6 +299,15 @@ func validateRequest(r *http.Request) (int, error) {
 	//return http.StatusUnsupportedMediaType, err
 }
 
+// writeNoContent ...
+func writeNoContent(w http.ResponseWriter) {
+	if HasWriteHeader(w) {
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+	SetWriteHeader(w)
+}
+
 func writeError(w http.ResponseWriter, status int, msg string) {
 	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 	w.WriteHeader(status)
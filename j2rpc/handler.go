@@ -6,24 +6,26 @@ type AfterHandler func(ctx *Context, method string, params []reflect.Value, resu
 
 type BeforeHandler func(ctx *Context, method string, params []reflect.Value) (err error)
 
+// CompleteHandler is called when a call is done, the error of the message is already mapped,
+// Method and Params of the message are still set
+type CompleteHandler func(c *Context)
+
 type Handler func(c *Context) (err error)
 
 type Handlers []Handler
 
 func UpsertHandlers(handlers Handlers, newHandlers Handlers) Handlers {
+	names := make(map[string]struct{}, len(handlers)+len(newHandlers))
+	for _, h := range handlers {
+		names[FuncNameFull(h)] = struct{}{}
+	}
 	for _, h := range newHandlers {
-		fn1 := FuncNameFull(h)
-		var duplicate = false
-		for _, nh := range handlers {
-			fn2 := FuncNameFull(nh)
-			if fn1 == fn2 {
-				duplicate = true
-				break
-			}
-		}
-		if !duplicate {
-			handlers = append(handlers, h)
+		name := FuncNameFull(h)
+		if _, ok := names[name]; ok {
+			continue
 		}
+		names[name] = struct{}{}
+		handlers = append(handlers, h)
 	}
 	return handlers
 }
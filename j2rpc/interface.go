@@ -1,9 +1,12 @@
 package j2rpc
 
 import (
+	"context"
+	"encoding/json"
 	"log"
 	"net/http"
 	"reflect"
+	"time"
 )
 
 type (
@@ -21,6 +24,7 @@ type (
 		SetCallAfter(after AfterHandler)
 		GetContext() *Context
 		Use(handlers ...Handler)
+		OnComplete(handlers ...CompleteHandler)
 		Opt() *Option
 		Logger() LevelLogger
 		SetLogger(logger LevelLogger)
@@ -28,14 +32,27 @@ type (
 		RegisterForApp(app interface{})
 		Register(receiver interface{}, names ...string)
 		Handler(ctx *Context)
-		Stop()
+		WebsocketHandler(ctx *Context)
+		Stop(ctx context.Context) error
 		NamespaceService(method string) interface{}
+		Discover() *OpenRPCDocument
+		Invoke(ctx context.Context, method string, params interface{}) (json.RawMessage, *Error)
 	}
 	//ItfNamespaceName ...
 	ItfNamespaceName interface {
 		J2rpcNamespaceName() string
 	}
 
+	//ItfNamespaceVersion the version of the namespace, then it's called as namespace@version.method,
+	//the name registered with a version (user@2) takes precedence over it
+	ItfNamespaceVersion interface {
+		J2rpcNamespaceVersion() string
+	}
+	//ItfNamespaceDeprecated the warning of the deprecated namespace, returned in the Warning header and logged
+	ItfNamespaceDeprecated interface {
+		J2rpcNamespaceDeprecated() string
+	}
+
 	ItfBeforeCall interface {
 		BeforeCall(ctx *Context, method string, args []reflect.Value) error
 	}
@@ -44,6 +61,43 @@ type (
 		AfterCall(ctx *Context, method string, args []reflect.Value, results []reflect.Value) error
 	}
 
+	//ItfParamNames declares the argument names (without context) of methods, key is the method name,
+	//then the params can be sent as a JSON object
+	ItfParamNames interface {
+		J2rpcParamNames() map[string][]string
+	}
+
+	//ItfTimeout declares the execution time limits of methods, key is the method name,
+	//the empty key applies to all methods of the receiver
+	ItfTimeout interface {
+		J2rpcTimeout() map[string]time.Duration
+	}
+
+	//ItfSkipValidate declares the methods whose arguments are not validated
+	ItfSkipValidate interface {
+		J2rpcSkipValidate() []string
+	}
+
+	//ItfMethodMeta declares the metadata of methods, key is the method name
+	ItfMethodMeta interface {
+		J2rpcMethodMeta() map[string]MethodMeta
+	}
+
+	//ItfUserID identifies the user in the rate limits
+	ItfUserID interface {
+		J2rpcUserID() string
+	}
+
+	//ItfUserRoles the roles of the user checked by MethodMeta.Roles
+	ItfUserRoles interface {
+		J2rpcRoles() []string
+	}
+
+	//ItfUserPermissions the permissions of the user checked by MethodMeta.Permissions
+	ItfUserPermissions interface {
+		J2rpcPermissions() []string
+	}
+
 	ItfStartup interface {
 		Startup(rs RPCServer)
 	}
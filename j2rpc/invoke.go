@@ -0,0 +1,80 @@
+package j2rpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+)
+
+// Invoke calls the method in process through the middlewares, the hooks and the callback, without HTTP.
+// params is a json.RawMessage, []byte, or a value marshalled as the params (slice for positional args,
+// struct or map for named args). If ctx is the *Context of a running call (a method can take *Context as its
+// context argument), the new call shares its request and copies its Store, so a service can call another one
+// and keep the auth values. A nil ctx is context.Background().
+func (s *server) Invoke(ctx context.Context, method string, params interface{}) (json.RawMessage, *Error) {
+	atomic.AddInt64(&s.pending, 1)
+	defer atomic.AddInt64(&s.pending, -1)
+	if atomic.LoadInt32(&s.run) == 0 {
+		return nil, NewError(ErrServer, "server is stopping")
+	}
+	msg := &RPCMessage{ID: json.RawMessage("1"), Version: vsn, Method: method}
+	switch v := params.(type) {
+	case nil:
+	case json.RawMessage:
+		msg.Params = v
+	case []byte:
+		msg.Params = v
+	default:
+		bts, err := json.Marshal(v)
+		if err != nil {
+			return nil, NewError(ErrBadParams, err.Error())
+		}
+		msg.Params = bts
+	}
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return nil, NewError(ErrInternal, err.Error())
+	}
+
+	c := s.GetContext()
+	defer c.Release()
+	w := &discardResponseWriter{header: make(http.Header)}
+	if parent, ok := ctx.(*Context); ok && parent == nil {
+		ctx = nil
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if parent, ok := ctx.(*Context); ok {
+		parentCtx := parent.Context
+		if parentCtx == nil && parent.Request() != nil {
+			parentCtx = parent.Request().Context()
+		}
+		if parentCtx == nil {
+			parentCtx = context.Background()
+		}
+		c.BeginWithContext(parentCtx, w, parent.Request())
+		copyStore(&c.Store, &parent.Store)
+	} else {
+		r, _ := http.NewRequestWithContext(ctx, http.MethodPost, "/", http.NoBody)
+		c.BeginWithContext(ctx, w, r)
+	}
+	if err = s.handle(c, body); err != nil {
+		c.Msg().setError(err, s.Opt().ErrorRegistry)
+	}
+	s.encodeResult(c)
+	s.complete(c)
+	return c.Msg().Result, c.Msg().Error
+}
+
+// discardResponseWriter the writer of in-process calls, the middlewares may write headers or body
+type discardResponseWriter struct {
+	header http.Header
+}
+
+func (d *discardResponseWriter) Header() http.Header { return d.header }
+
+func (d *discardResponseWriter) Write(b []byte) (int, error) { return len(b), nil }
+
+func (d *discardResponseWriter) WriteHeader(int) {}
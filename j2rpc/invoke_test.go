@@ -0,0 +1,33 @@
+package j2rpc
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInvokeContext(t *testing.T) {
+	s := New(&Option{SnakeNamespace: true})
+	s.Register(new(clientService), "calc")
+	tests := []struct {
+		name string
+		ctx  context.Context
+	}{
+		{"background", context.Background()},
+		{"nil", nil},
+		{"nil *Context", (*Context)(nil)},
+		{"*Context without context", new(Context)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var result json.RawMessage
+			var rpcErr *Error
+			require.NotPanics(t, func() { result, rpcErr = s.Invoke(tt.ctx, "calc.add", []int{1, 2}) })
+			require.Nil(t, rpcErr)
+			assert.JSONEq(t, "3", string(result))
+		})
+	}
+}
@@ -5,26 +5,34 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"reflect"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/andeya/goutil"
+
+	"github.com/atcharles/glibs/util"
 )
 
 const (
 	vsn = "2.0"
 
 	splitMethodSeparator = "."
+
+	// StopPendingRequestTimeout default time to wait for pending requests when the server stops
+	StopPendingRequestTimeout = time.Second * 10
+	// stopRetryAfter seconds after which the client should retry a request rejected by a stopped server
+	stopRetryAfter = 5
 )
 
 var (
-	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
-	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+	contextType    = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType      = reflect.TypeOf((*error)(nil)).Elem()
+	rpcContextType = reflect.TypeOf((*Context)(nil))
 )
 
 type (
@@ -32,18 +40,29 @@ type (
 		mutex          sync.Mutex
 		opt            *Option
 		run            int32
+		pending        int64
 		services       map[string]service
 		logger         LevelLogger
 		excludeMethods []string
 		midHandlers    Handlers
+		onComplete     []CompleteHandler
+		websockets     map[*wsConn]struct{}
+		envelopes      envelopeCache
+		limiters       rateLimiters
+		deprecations   deprecationLogs
 		contextPool    *ContextPool
 		callBefore     BeforeHandler
 		callAfter      AfterHandler
 	}
 	service struct {
-		name      string
-		receiver  interface{}
-		callbacks map[string]callback
+		name string
+		//namespace the name without version
+		namespace string
+		version   string
+		//deprecated the warning of a deprecated namespace, see ItfNamespaceDeprecated
+		deprecated string
+		receiver   interface{}
+		callbacks  map[string]callback
 	}
 )
 
@@ -55,11 +74,17 @@ func (s *server) GetContext() *Context {
 // Handler ...
 func (s *server) Handler(ctx *Context) {
 	defer func() { ctx.Release() }()
+	w, r := ctx.Writer(), ctx.Request()
+	// count the request before checking run, so Stop never misses it
+	atomic.AddInt64(&s.pending, 1)
+	defer atomic.AddInt64(&s.pending, -1)
 	// Don't serve if server is stopped.
 	if atomic.LoadInt32(&s.run) == 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(stopRetryAfter))
+		msg := RPCError(NewError(ErrServer, "server is stopping", map[string]interface{}{"retry_after": stopRetryAfter}))
+		writeJSONResponseStatus(w, s.Opt().bodyParser(), http.StatusServiceUnavailable, msg.output())
 		return
 	}
-	w, r := ctx.Writer(), ctx.Request()
 	//检测是否已经写入header
 	if HasWriteHeader(w) {
 		return
@@ -71,14 +96,39 @@ func (s *server) Handler(ctx *Context) {
 	// Prevents Internet Explorer from MIME-sniffing a response away
 	// from the declared content-type
 	w.Header().Set("x-content-type-options", "nosniff")
-	if err := s.handle(ctx); err != nil {
-		ctx.Msg().setError(err)
+	ctx.fields = requestFields(r)
+	body, err := s.readBody(ctx)
+	if err == nil && isBatchBody(body) {
+		buf := s.handleBatch(ctx, body)
+		if buf == nil {
+			writeNoContent(w)
+			return
+		}
+		defer releaseBuffer(buf)
+		writeBody(w, ctx.parseBody, http.StatusOK, buf.Bytes())
+		s.debugRequest(w, json.RawMessage(buf.Bytes()))
+		return
 	}
-	ctx.Msg().output().writeResponse(w, s.Opt())
-	requestID := w.Header().Get("request-id")
-	if len(requestID) > 0 {
-		s.logger.Debugf("[Request-ID:%s] %s", requestID, JSONDump(ctx.Msg()))
+	if err == nil {
+		err = s.handle(ctx, body)
+	}
+	if err != nil {
+		ctx.Msg().setError(err, s.Opt().ErrorRegistry)
+	}
+	if ctx.Msg().isNotification() {
+		s.complete(ctx)
+		s.logNotificationError(ctx.Msg(), err)
+		writeNoContent(w)
+		return
+	}
+	buf := s.encodeResponse(ctx)
+	defer releaseBuffer(buf)
+	s.complete(ctx)
+	if HasWriteHeader(w) {
+		return
 	}
+	writeBody(w, ctx.parseBody, http.StatusOK, buf.Bytes())
+	s.debugRequest(w, json.RawMessage(buf.Bytes()))
 }
 
 func (s *server) Logger() LevelLogger {
@@ -90,7 +140,7 @@ func (s *server) Logger() LevelLogger {
 
 func (s *server) NamespaceService(method string) interface{} {
 	elem := strings.SplitN(method, splitMethodSeparator, 2)
-	svs, ok := s.services[elem[0]]
+	svs, ok := s.lookupService(nil, elem[0])
 	if !ok {
 		return nil
 	}
@@ -100,6 +150,14 @@ func (s *server) NamespaceService(method string) interface{} {
 // Opt ...
 func (s *server) Opt() *Option { return s.opt }
 
+// OnComplete adds the handlers called when each call is done, including the elements of batch requests,
+// the notifications and the in-process calls. They run before the response is written.
+func (s *server) OnComplete(handlers ...CompleteHandler) {
+	s.mutex.Lock()
+	s.onComplete = append(s.onComplete, handlers...)
+	s.mutex.Unlock()
+}
+
 // Register ...
 func (s *server) Register(receiver interface{}, names ...string) {
 	var _fnGetServiceName = func(rv interface{}) string {
@@ -117,6 +175,15 @@ func (s *server) Register(receiver interface{}, names ...string) {
 		return name
 	}
 	serviceName := _fnGetServiceName(receiver)
+	if nv, ok := receiver.(ItfNamespaceVersion); ok && !strings.Contains(serviceName, versionSeparator) {
+		if version := nv.J2rpcNamespaceVersion(); len(version) > 0 {
+			serviceName += versionSeparator + version
+		}
+	}
+	deprecated, ok := s.opt.DeprecatedNamespaces[serviceName]
+	if nd, isDeprecated := receiver.(ItfNamespaceDeprecated); isDeprecated && !ok {
+		deprecated = nd.J2rpcNamespaceDeprecated()
+	}
 
 	if consVal, ok := receiver.(ItfConstructor); ok {
 		consVal.Constructor()
@@ -134,13 +201,23 @@ func (s *server) Register(receiver interface{}, names ...string) {
 	}
 
 	srv, ok := s.services[serviceName]
-	if ok {
+	switch {
+	case ok && isBuiltin(receiver):
+		// the rpc namespace of the application is kept
+		return
+	case ok && isBuiltin(srv.receiver):
+		s.Logger().Warnf("namespace [%s] replaces the built-in %s.discover", serviceName, DiscoverNamespace)
+	case ok:
 		panic(fmt.Sprintf("namespace [%s] exists", serviceName))
 	}
+	namespace, version := splitVersion(serviceName)
 	srv = service{
-		name:      serviceName,
-		receiver:  receiver,
-		callbacks: make(map[string]callback),
+		name:       serviceName,
+		namespace:  namespace,
+		version:    version,
+		deprecated: deprecated,
+		receiver:   receiver,
+		callbacks:  make(map[string]callback),
 	}
 	s.services[serviceName] = srv
 	for name, cb := range callbacks {
@@ -181,13 +258,25 @@ func (s *server) SetCallBefore(callBefore BeforeHandler) {
 
 func (s *server) SetLogger(logger LevelLogger) { s.logger = logger }
 
-// Stop stops reading new requests, waits for stopPendingRequestTimeout to allow pending
-// requests to finish, then closes all codecs which will cancel pending requests and
-// subscriptions.
-func (s *server) Stop() {
+// Stop stops reading new requests, new requests are rejected with ErrServer and a retry hint.
+// It waits for the pending requests to finish until ctx is done, and returns ctx.Err() if they don't.
+// The methods still running after their timeout are pending too.
+func (s *server) Stop(ctx context.Context) error {
 	if atomic.CompareAndSwapInt32(&s.run, 1, 0) {
-		s.logger.Debugf("RPC server shutting down")
+		s.Logger().Debugf("RPC server shutting down")
+		s.closeWebsockets()
+	}
+	tk := time.NewTicker(time.Millisecond * 10)
+	defer tk.Stop()
+	for atomic.LoadInt64(&s.pending) > 0 {
+		select {
+		case <-ctx.Done():
+			s.Logger().Warnf("RPC server stopped with %d pending requests", atomic.LoadInt64(&s.pending))
+			return ctx.Err()
+		case <-tk.C:
+		}
 	}
+	return nil
 }
 
 // Use ......
@@ -206,13 +295,14 @@ func (s *server) formatName(name string) string {
 	return name
 }
 
-// getCallBack ...
-func (s *server) getCallBack(elem []string) (cbk callback, err error) {
-	svs, ok := s.services[elem[0]]
+// getCallBack resolves the version of the namespace, elem[0] is set to the name of the service
+func (s *server) getCallBack(r *http.Request, elem []string) (svs service, cbk callback, err error) {
+	svs, ok := s.lookupService(r, elem[0])
 	if !ok {
 		err = NewError(ErrNoMethod, "no namespace")
 		return
 	}
+	elem[0] = svs.name
 	cbk, ok = svs.callbacks[elem[1]]
 	if !ok {
 		err = NewError(ErrNoMethod, "no method")
@@ -221,39 +311,117 @@ func (s *server) getCallBack(elem []string) (cbk callback, err error) {
 	return
 }
 
-// handle ...
-func (s *server) handle(c *Context) (err error) {
-	defer func() { _ = c.Request().Body.Close() }()
-	// read request body
-	var body []byte
-	if c.Request().Body != nil {
-		var e error
-		body, e = io.ReadAll(c.Request().Body)
-		if e != nil && e != io.EOF {
-			err = e
-			return
+// complete records the time used if the callback didn't, then runs the complete handlers
+func (s *server) complete(c *Context) {
+	if _, ok := c.Store.Load(TimeUsedKey); !ok {
+		if _val, ok := c.Store.Load(StartTimeKey); ok {
+			now := time.Now().UnixNano()
+			c.Store.Store(EndTimeKey, now)
+			c.Store.Store(TimeUsedKey, now-_val.(int64))
 		}
 	}
-	//重新设置body
-	//c.Request().Body = io.NopCloser(bytes.NewReader(body))
-	body, err = s.Opt().ParseRPCBody(body, true)
-	if err != nil {
+	s.mutex.Lock()
+	handlers := s.onComplete
+	s.mutex.Unlock()
+	for _, h := range handlers {
+		h(c)
+	}
+}
+
+// debugRequest ...
+func (s *server) debugRequest(w http.ResponseWriter, val interface{}) {
+	requestID := w.Header().Get("request-id")
+	if len(requestID) > 0 {
+		s.logger.Debugf("[Request-ID:%s] %s", requestID, JSONDump(val))
+	}
+}
+
+// handle decodes the body into the message of c and processes it, the body is owned by c since then
+func (s *server) handle(c *Context, body []byte) (err error) {
+	if err = s.decode(c, body); err != nil {
 		return
 	}
-	c.Body = make([]byte, len(body))
-	copy(c.Body, body)
+	return s.process(c)
+}
+
+// decode ...
+func (s *server) decode(c *Context, body []byte) error {
+	c.Body = body
 	c.Store.Store(StartTimeKey, time.Now().UnixNano())
+	if err := json.Unmarshal(body, c.Msg()); err != nil {
+		return NewError(ErrParse, "请求错误")
+	}
+	return nil
+}
 
+// process calls the decoded message, the result is projected to the fields of the call, or it's kept by c until it's encoded by encodeResponse or encodeResult
+func (s *server) process(c *Context) (err error) {
 	msg := c.Msg()
-	err = json.NewDecoder(bytes.NewReader(body)).Decode(msg)
+	if !msg.isNotification() && !msg.hasValidID() {
+		err = NewError(ErrInvalidRequest, "id is invalid")
+		return
+	}
+	res, err := s.callMessage(c)
 	if err != nil {
-		err = NewError(ErrParse, "请求错误")
 		return
 	}
-	if !msg.hasValidID() {
-		err = NewError(ErrInvalidRequest, "id is invalid")
+	val := reflect.ValueOf(res)
+	if !val.IsValid() {
+		return
+	}
+	if val.IsZero() {
+		return
+	}
+	if val.Kind() == reflect.Chan {
+		err = NewError(ErrInvalidRequest, "the method is a subscription, subscribe it over websocket")
+		return
+	}
+	if fields := c.Fields(); len(fields) > 0 {
+		answer, err := json.Marshal(res)
+		if err != nil {
+			return err
+		}
+		msg.Result = fields.Project(answer)
+		return nil
+	}
+	c.result = res
+	return
+}
+
+// encodeResult marshals the result of c into the message, for the calls of Invoke which return the result
+func (s *server) encodeResult(c *Context) {
+	if c.result == nil || c.Msg().isNotification() {
 		return
 	}
+	answer, err := json.Marshal(c.result)
+	c.result = nil
+	if err != nil {
+		c.Msg().setError(err, s.Opt().ErrorRegistry)
+		return
+	}
+	c.Msg().Result = answer
+}
+
+// encodeResponse encodes the response of c with its result straight into a pooled buffer,
+// the error of the encoding takes the place of the result
+func (s *server) encodeResponse(c *Context) *bytes.Buffer {
+	buf := acquireBuffer()
+	// the method and params of the message are kept for the complete handlers
+	out := *c.Msg()
+	err := out.output().encode(buf, c.result)
+	if err != nil {
+		buf.Reset()
+		c.result = nil
+		c.Msg().setError(err, s.Opt().ErrorRegistry)
+		out.Result, out.Error = nil, c.Msg().Error
+		_ = out.encode(buf, nil)
+	}
+	return buf
+}
+
+// callMessage resolves the method of the decoded message, runs the middlewares and calls the callback
+func (s *server) callMessage(c *Context) (res interface{}, err error) {
+	msg := c.Msg()
 	elem, err := msg.methods()
 	if err != nil {
 		return
@@ -262,12 +430,14 @@ func (s *server) handle(c *Context) (err error) {
 		//elem[i] = s.formatName(CamelString(e2))
 		elem[i] = s.formatName(e2)
 	}
+	svs, cbk, err := s.getCallBack(c.Request(), elem)
 	msg.Method = strings.Join(elem, splitMethodSeparator)
-	cbk, err := s.getCallBack(elem)
 	if err != nil {
 		return
 	}
 	cbk.providerMethod = msg.Method
+	c.meta = cbk.meta
+	s.versionCalled(c, svs)
 	// context do middleware
 	if err = c.Do(s.midHandlers...); err != nil {
 		return
@@ -275,28 +445,60 @@ func (s *server) handle(c *Context) (err error) {
 	if c.IsStopped() {
 		return
 	}
+	if err = s.enforceMeta(c, cbk.meta); err != nil {
+		return
+	}
 	// call method
-	callArgs, err := ParsePositionalArguments(msg.Params, cbk.argTypes)
+	callArgs, err := cbk.decoder.decode(msg.Params)
 	if err != nil {
 		err = NewError(ErrBadParams, err.Error())
 		return
 	}
-	res, err := cbk.call(c, callArgs)
-	if err != nil {
+	c.args = callArgs
+	if err = cbk.validateArguments(callArgs); err != nil {
 		return
 	}
-	val := reflect.ValueOf(res)
-	if !val.IsValid() {
+	return cbk.call(c, callArgs)
+}
+
+// encodeMessage encodes msg into a pooled buffer
+func (s *server) encodeMessage(msg *RPCMessage) *bytes.Buffer {
+	buf := acquireBuffer()
+	if err := msg.encode(buf, nil); err != nil {
+		buf.Reset()
+		_ = RPCError(NewError(ErrInternal, err.Error())).output().encode(buf, nil)
+	}
+	return buf
+}
+
+// logNotificationError logs the error of a notification, which never gets a response
+func (s *server) logNotificationError(msg *RPCMessage, err error) {
+	if err == nil {
 		return
 	}
-	if val.IsZero() {
+	s.Logger().Errorf("[Notification] %s: %s", msg.Method, err.Error())
+}
+
+// readBody reads the request body and decrypts it with the envelope session or the crypto key
+func (s *server) readBody(c *Context) (body []byte, err error) {
+	if c.parseBody, err = s.requestBodyParser(c.Request()); err != nil {
 		return
 	}
-	answer, err := json.Marshal(res)
-	if err != nil {
+	if c.Request().Body == nil {
 		return
 	}
-	msg.Result = answer
+	defer func() { _ = c.Request().Body.Close() }()
+	c.buf = acquireBuffer()
+	if n := c.Request().ContentLength; n > 0 && n <= maxPooledBufferSize {
+		c.buf.Grow(int(n))
+	}
+	if _, err = c.buf.ReadFrom(c.Request().Body); err != nil {
+		return
+	}
+	body = c.buf.Bytes()
+	if body, err = c.parseBody.decrypt(body); err != nil && s.Opt().Envelope != nil {
+		err = NewError(ErrParse, "invalid encrypted body")
+	}
 	return
 }
 
@@ -309,7 +511,7 @@ func (s *server) stack(recover interface{}, methodName string) error {
 	buf = buf[:runtime.Stack(buf, false)]*/
 
 	buf := goutil.PanicTrace(4)
-	s.logger.Errorf("RPC handler crashed:\n%s\n%s", msg, string(buf))
+	s.Logger().Errorf("RPC handler crashed:\n%s\n%s", msg, string(buf))
 	return NewError(ErrInternal, msg)
 }
 
@@ -318,7 +520,8 @@ func (s *server) suitableCallbacks(receiver interface{}) (callbacks map[string]c
 	callbacks = make(map[string]callback)
 
 	var skipMethods = append(
-		[]string{"Constructor", "ExcludeMethod", "BeforeCall", "AfterCall"},
+		[]string{"Constructor", "ExcludeMethod", "BeforeCall", "AfterCall", "J2rpcParamNames", "J2rpcTimeout", "J2rpcSkipValidate", "J2rpcMethodMeta",
+			"J2rpcNamespaceVersion", "J2rpcNamespaceDeprecated"},
 		s.excludeMethods...,
 	)
 	if exv, ok := receiver.(ItfExcludeMethod); ok {
@@ -355,6 +558,25 @@ func (s *server) suitableCallbacks(receiver interface{}) (callbacks map[string]c
 		if ok := c.makeArgTypes(); !ok {
 			return
 		}
+		if pn, ok := receiver.(ItfParamNames); ok {
+			c.argNames = pn.J2rpcParamNames()[method.Name]
+		}
+		if sv, ok := receiver.(ItfSkipValidate); ok {
+			c.skipValidate = util.Contains(sv.J2rpcSkipValidate(), method.Name)
+		}
+		c.decoder = newArgDecoder(c.argTypes, c.argNames)
+		if tv, ok := receiver.(ItfTimeout); ok {
+			timeouts := tv.J2rpcTimeout()
+			c.methodTimeout, c.namespaceTimeout = timeouts[method.Name], timeouts[""]
+		}
+		if mv, ok := receiver.(ItfMethodMeta); ok {
+			if meta, ok := mv.J2rpcMethodMeta()[method.Name]; ok {
+				c.meta = &meta
+				if c.methodTimeout == 0 {
+					c.methodTimeout = meta.Timeout
+				}
+			}
+		}
 		callbacks[s.formatName(method.Name)] = c
 	}
 
@@ -379,5 +601,8 @@ func New(opts ...*Option) RPCServer {
 	}
 	s.logger = s.opt.Logger
 	s.contextPool = newContextPool(func() interface{} { return newContext(s) })
+	if s.opt.EnableDiscover {
+		s.Register(&rpcService{s: s}, DiscoverNamespace)
+	}
 	return s
 }
@@ -1,6 +1,7 @@
 package j2rpc
 
 import (
+	"bytes"
 	"encoding/json"
 	"net/http"
 	"strings"
@@ -13,22 +14,94 @@ type RPCMessage struct {
 	Version string          `json:"jsonrpc,omitempty"`
 	Method  string          `json:"method,omitempty"`
 	Params  json.RawMessage `json:"params,omitempty"`
+	Fields  Fields          `json:"fields,omitempty"`
 	Result  json.RawMessage `json:"result,omitempty"`
 	Error   *Error          `json:"error,omitempty"`
 }
 
+// decodeResult returns the error of the response, or unmarshalls the result into val
+func (r *RPCMessage) decodeResult(val interface{}) error {
+	if r.Error != nil {
+		return r.Error
+	}
+	if val == nil || len(r.Result) == 0 {
+		return nil
+	}
+	return json.Unmarshal(r.Result, val)
+}
+
+// encode writes the message as json.Marshal does, result is encoded in place of an empty Result
+func (r *RPCMessage) encode(buf *bytes.Buffer, result interface{}) error {
+	enc := json.NewEncoder(buf)
+	start := buf.Len()
+	buf.WriteByte('{')
+	key := func(name string) {
+		if buf.Len() > start+1 {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(name)
+	}
+	if len(r.ID) > 0 {
+		key(`"id":`)
+		buf.Write(r.ID)
+	}
+	if len(r.Version) > 0 {
+		key(`"jsonrpc":`)
+		if err := encodeValue(enc, buf, r.Version); err != nil {
+			return err
+		}
+	}
+	if len(r.Method) > 0 {
+		key(`"method":`)
+		if err := encodeValue(enc, buf, r.Method); err != nil {
+			return err
+		}
+	}
+	if len(r.Params) > 0 {
+		key(`"params":`)
+		buf.Write(r.Params)
+	}
+	if len(r.Fields) > 0 {
+		key(`"fields":`)
+		if err := encodeValue(enc, buf, r.Fields); err != nil {
+			return err
+		}
+	}
+	if len(r.Result) > 0 {
+		key(`"result":`)
+		buf.Write(r.Result)
+	} else if result != nil {
+		key(`"result":`)
+		if err := encodeValue(enc, buf, result); err != nil {
+			return err
+		}
+	}
+	if r.Error != nil {
+		key(`"error":`)
+		if err := encodeValue(enc, buf, r.Error); err != nil {
+			return err
+		}
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
 // empty ......
 func (r *RPCMessage) empty() {
 	r.ID = nil
 	r.Version = ""
 	r.Method = ""
 	r.Params = nil
+	r.Fields = nil
 	r.Result = nil
 	r.Error = nil
 }
 
 func (r *RPCMessage) hasValidID() bool { return len(r.ID) > 0 && r.ID[0] != '{' && r.ID[0] != '[' }
 
+// isNotification reports whether the message is a call without id, which expects no response
+func (r *RPCMessage) isNotification() bool { return len(r.ID) == 0 && len(r.Method) > 0 }
+
 // namespace ...returns the service's name
 func (r *RPCMessage) methods() ([]string, error) {
 	elem := strings.SplitN(r.Method, splitMethodSeparator, 2)
@@ -41,67 +114,75 @@ func (r *RPCMessage) methods() ([]string, error) {
 // output ...
 func (r *RPCMessage) output() *RPCMessage {
 	if len(r.ID) == 0 {
-		r.ID = []byte{'1'}
+		r.ID = []byte("null")
 	}
 	r.Version = vsn
 	r.Method = ""
 	r.Params = nil
+	r.Fields = nil
 	return r
 }
 
-// setError ...
-func (r *RPCMessage) setError(err error) *RPCMessage {
+// setError maps err with the registry, nil registry means DefaultErrorRegistry
+func (r *RPCMessage) setError(err error, registry *ErrorRegistry) *RPCMessage {
 	if err == nil {
 		return r
 	}
-	var e = NewError(ErrInternal, err.Error())
-	switch v := err.(type) {
-	case ItfJ2rpcError:
-		e.Code = ErrorCode(v.ErrorCode())
-		e.Message = v.Error()
-		e.Data = v.ErrorData()
-	case IRPCError:
-		e = v.RPCError()
-	case TokenError:
-		e.Code = ErrAuthorization
-		e.Message = v.Error()
-	case ForbiddenError:
-		e.Code = ErrForbidden
-		e.Message = v.Error()
-	}
-	r.Error = e
+	if registry == nil {
+		registry = DefaultErrorRegistry
+	}
+	r.Error = registry.Map(err)
 	return r
 }
 
-// writeResponse ...
-func (r *RPCMessage) writeResponse(w http.ResponseWriter, opt *Option) {
+// encodeValue encodes val without the trailing newline of json.Encoder
+func encodeValue(enc *json.Encoder, buf *bytes.Buffer, val interface{}) error {
+	if err := enc.Encode(val); err != nil {
+		return err
+	}
+	buf.Truncate(buf.Len() - 1)
+	return nil
+}
+
+func RPCError(err error) *RPCMessage { return new(RPCMessage).setError(err, nil) }
+
+func RPCResult(result interface{}) *RPCMessage {
+	r := new(RPCMessage)
+	r.Result, _ = json.Marshal(result)
+	return r.output()
+}
+
+// writeJSONResponse marshals val, encrypts it with parse and writes it to w
+func writeJSONResponse(w http.ResponseWriter, parse bodyParser, val interface{}) {
+	writeJSONResponseStatus(w, parse, http.StatusOK, val)
+}
+
+// writeJSONResponseStatus ...
+func writeJSONResponseStatus(w http.ResponseWriter, parse bodyParser, status int, val interface{}) {
 	if HasWriteHeader(w) {
 		return
 	}
-	bts, err := json.Marshal(r)
+	bts, err := json.Marshal(val)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
-	w.WriteHeader(http.StatusOK)
-	bts, err = opt.ParseRPCBody(bts, false)
+	writeBody(w, parse, status, bts)
+}
+
+// writeBody encrypts bts with parse and writes it to w
+func writeBody(w http.ResponseWriter, parse bodyParser, status int, bts []byte) {
+	bts, err := parse.encrypt(bts)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	if len(opt.CryptoKey) >= 16 {
+	if parse != nil {
 		w.Header().Set("Content-Type", "text/plain")
 	}
+	w.WriteHeader(status)
 	_, _ = w.Write(bts)
 	//n, err := w.Write(bts)
 	//_, _ = n, err
 }
-
-func RPCError(err error) *RPCMessage { return new(RPCMessage).setError(err) }
-
-func RPCResult(result interface{}) *RPCMessage {
-	r := new(RPCMessage)
-	r.Result, _ = json.Marshal(result)
-	return r.output()
-}
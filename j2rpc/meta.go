@@ -0,0 +1,172 @@
+package j2rpc
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	// UserKey the key of the caller in Context.Store, see Context.SetUser
+	UserKey = "__j2rpc_userKey"
+
+	// limiterIdle a rate limiter unused for it is removed
+	limiterIdle = time.Minute * 10
+)
+
+type (
+	// MethodMeta declares the behaviour of a method, it's enforced after the middlewares and before the call
+	MethodMeta struct {
+		//Auth the caller must be authenticated, the user is set by Context.SetUser or Option.UserResolver
+		Auth bool
+		//Roles the user must have one of them, by ItfUserRoles, implies Auth
+		Roles []string
+		//Permissions the user must have all of them, by ItfUserPermissions, implies Auth
+		Permissions []string
+		//RateLimit calls per second of each caller (ItfUserID or the remote IP), 0 is no limit
+		RateLimit float64
+		//RateBurst max calls at once of each caller, default is 1
+		RateBurst int
+		//Timeout execution time limit, see ItfTimeout
+		Timeout time.Duration
+		//Deprecated the response has the Deprecation header, and the method is deprecated in the discovery
+		Deprecated bool
+		//Hidden the method is not published in the discovery
+		Hidden bool
+	}
+
+	// rateLimiters the limiters of the methods, key is method and caller
+	rateLimiters struct {
+		mu       sync.Mutex
+		limiters map[string]*methodLimiter
+		sweepAt  time.Time
+	}
+
+	methodLimiter struct {
+		*rate.Limiter
+		lastSeen time.Time
+	}
+)
+
+// allow ...
+func (r *rateLimiters) allow(key string, limit float64, burst int) bool {
+	now := time.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.limiters == nil {
+		r.limiters = make(map[string]*methodLimiter)
+	}
+	if now.After(r.sweepAt) {
+		for k, l := range r.limiters {
+			if now.Sub(l.lastSeen) > limiterIdle {
+				delete(r.limiters, k)
+			}
+		}
+		r.sweepAt = now.Add(limiterIdle)
+	}
+	l, ok := r.limiters[key]
+	if !ok {
+		if burst < 1 {
+			burst = 1
+		}
+		l = &methodLimiter{Limiter: rate.NewLimiter(rate.Limit(limit), burst)}
+		r.limiters[key] = l
+	}
+	l.lastSeen = now
+	return l.AllowN(now, 1)
+}
+
+// enforceMeta checks the metadata of the method before the call
+func (s *server) enforceMeta(c *Context, meta *MethodMeta) error {
+	if meta == nil {
+		return nil
+	}
+	if meta.Deprecated {
+		c.Writer().Header().Set("Deprecation", "true")
+	}
+	var user interface{}
+	if meta.Auth || len(meta.Roles) > 0 || len(meta.Permissions) > 0 {
+		if user = c.User(); user == nil {
+			return NewError(ErrAuthorization, "unauthorized")
+		}
+	}
+	if len(meta.Roles) > 0 && !hasAnyRole(user, meta.Roles) {
+		return NewError(ErrForbidden, "forbidden")
+	}
+	if len(meta.Permissions) > 0 && !hasAllPermissions(user, meta.Permissions) {
+		return NewError(ErrForbidden, "forbidden")
+	}
+	if meta.RateLimit > 0 && !s.limiters.allow(c.Method()+"|"+callerKey(c), meta.RateLimit, meta.RateBurst) {
+		return NewError(ErrTooManyRequests, "too many requests")
+	}
+	return nil
+}
+
+// MethodMeta returns the metadata of the called method, nil if it has none
+func (c *Context) MethodMeta() *MethodMeta { return c.meta }
+
+// SetUser sets the caller, e.g. by the authentication middleware, it's checked by MethodMeta
+func (c *Context) SetUser(user interface{}) { c.Store.Store(UserKey, user) }
+
+// User returns the caller set by SetUser, otherwise resolved by Option.UserResolver
+func (c *Context) User() interface{} {
+	if user, ok := c.Store.Load(UserKey); ok && user != nil {
+		return user
+	}
+	if resolver := c.app.Opt().UserResolver; resolver != nil {
+		return resolver(c)
+	}
+	return nil
+}
+
+// callerKey the user id, or the remote IP
+func callerKey(c *Context) string {
+	if user, ok := c.User().(ItfUserID); ok {
+		return "user:" + user.J2rpcUserID()
+	}
+	if c.Request() == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(c.Request().RemoteAddr)
+	if err != nil {
+		host = c.Request().RemoteAddr
+	}
+	return fmt.Sprintf("ip:%s", host)
+}
+
+// hasAllPermissions ...
+func hasAllPermissions(user interface{}, permissions []string) bool {
+	up, ok := user.(ItfUserPermissions)
+	if !ok {
+		return false
+	}
+	owned := make(map[string]struct{})
+	for _, p := range up.J2rpcPermissions() {
+		owned[p] = struct{}{}
+	}
+	for _, p := range permissions {
+		if _, ok = owned[p]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// hasAnyRole ...
+func hasAnyRole(user interface{}, roles []string) bool {
+	ur, ok := user.(ItfUserRoles)
+	if !ok {
+		return false
+	}
+	for _, owned := range ur.J2rpcRoles() {
+		for _, role := range roles {
+			if owned == role {
+				return true
+			}
+		}
+	}
+	return false
+}
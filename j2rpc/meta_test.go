@@ -0,0 +1,144 @@
+package j2rpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type (
+	metaService struct{}
+
+	metaUser struct {
+		id          string
+		roles       []string
+		permissions []string
+	}
+)
+
+func (u *metaUser) J2rpcUserID() string { return u.id }
+
+func (u *metaUser) J2rpcRoles() []string { return u.roles }
+
+func (u *metaUser) J2rpcPermissions() []string { return u.permissions }
+
+func (s *metaService) J2rpcMethodMeta() map[string]MethodMeta {
+	return map[string]MethodMeta{
+		"Auth":    {Auth: true},
+		"Admin":   {Roles: []string{"admin", "root"}},
+		"Write":   {Permissions: []string{"read", "write"}},
+		"Limited": {RateLimit: 0.001, RateBurst: 2},
+		"Old":     {Deprecated: true},
+	}
+}
+
+func (s *metaService) Auth(_ context.Context) (string, error) { return "ok", nil }
+
+func (s *metaService) Admin(_ context.Context) (string, error) { return "ok", nil }
+
+func (s *metaService) Write(_ context.Context) (string, error) { return "ok", nil }
+
+func (s *metaService) Limited(_ context.Context) (string, error) { return "ok", nil }
+
+func (s *metaService) Old(_ context.Context) (string, error) { return "ok", nil }
+
+func TestMethodMeta(t *testing.T) {
+	users := map[string]*metaUser{
+		"alice": {id: "alice", roles: []string{"admin"}, permissions: []string{"read", "write"}},
+		"bob":   {id: "bob", roles: []string{"guest"}, permissions: []string{"read"}},
+	}
+	s := New(&Option{SnakeNamespace: true, UserResolver: func(c *Context) interface{} {
+		if user, ok := users[c.Request().Header.Get("X-User")]; ok {
+			return user
+		}
+		return nil
+	}})
+	s.Register(new(metaService), "meta")
+	call := func(method, user, remote string) (*httptest.ResponseRecorder, *RPCMessage) {
+		body := `{"jsonrpc":"2.0","id":1,"method":"` + method + `","params":[]}`
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		r.Header.Set("X-User", user)
+		r.RemoteAddr = remote
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		msg := new(RPCMessage)
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), msg), w.Body.String())
+		return w, msg
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		user   string
+		remote string
+		// code the error code, 0 if the call succeeds
+		code ErrorCode
+	}{
+		{"auth", "meta.auth", "bob", "10.0.0.1:1", 0},
+		{"auth anonymous", "meta.auth", "", "10.0.0.1:1", ErrAuthorization},
+		{"role", "meta.admin", "alice", "10.0.0.1:1", 0},
+		{"role missing", "meta.admin", "bob", "10.0.0.1:1", ErrForbidden},
+		{"role anonymous", "meta.admin", "", "10.0.0.1:1", ErrAuthorization},
+		{"permissions", "meta.write", "alice", "10.0.0.1:1", 0},
+		{"permission missing", "meta.write", "bob", "10.0.0.1:1", ErrForbidden},
+		{"limited 1", "meta.limited", "", "10.0.0.1:1", 0},
+		{"limited 2", "meta.limited", "", "10.0.0.1:2", 0},
+		{"limited over burst", "meta.limited", "", "10.0.0.1:3", ErrTooManyRequests},
+		{"limited other ip", "meta.limited", "", "10.0.0.2:1", 0},
+		{"limited user", "meta.limited", "alice", "10.0.0.1:1", 0},
+		{"deprecated", "meta.old", "", "10.0.0.1:1", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, msg := call(tt.method, tt.user, tt.remote)
+			if tt.code == 0 {
+				require.Nil(t, msg.Error)
+				assert.Equal(t, `"ok"`, string(msg.Result))
+				return
+			}
+			require.NotNil(t, msg.Error)
+			assert.Equal(t, tt.code, msg.Error.Code)
+		})
+	}
+
+	w, _ := call("meta.old", "", "10.0.0.1:1")
+	assert.Equal(t, "true", w.Header().Get("Deprecation"))
+	w, _ = call("meta.auth", "bob", "10.0.0.1:1")
+	assert.Empty(t, w.Header().Get("Deprecation"))
+}
+
+func TestMethodMetaSetUser(t *testing.T) {
+	s := New(&Option{SnakeNamespace: true})
+	s.Register(new(metaService), "meta")
+	s.Use(func(c *Context) error {
+		if c.Request().Header.Get("X-User") != "" {
+			c.SetUser(&metaUser{id: "carol", roles: []string{"root"}})
+		}
+		return nil
+	})
+	for _, tt := range []struct {
+		user string
+		code ErrorCode
+	}{{"carol", 0}, {"", ErrAuthorization}} {
+		user, code := tt.user, tt.code
+		body := `{"jsonrpc":"2.0","id":1,"method":"meta.admin","params":[]}`
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		r.Header.Set("X-User", user)
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		msg := new(RPCMessage)
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), msg))
+		if code == 0 {
+			assert.Nil(t, msg.Error, user)
+			continue
+		}
+		require.NotNil(t, msg.Error, user)
+		assert.Equal(t, code, msg.Error.Code)
+	}
+}
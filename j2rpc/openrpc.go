@@ -0,0 +1,410 @@
+package j2rpc
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DiscoverNamespace namespace of the built-in rpc.discover method, see Option.EnableDiscover
+	DiscoverNamespace = "rpc"
+
+	openRPCVersion = "1.2.6"
+	schemaRefRoot  = "#/components/schemas/"
+	errorRefRoot   = "#/components/errors/"
+)
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	rawMessageType    = reflect.TypeOf(json.RawMessage{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// declaredErrors error codes of error.go, published in the OpenRPC document
+var declaredErrors = []struct {
+	name string
+	code ErrorCode
+	msg  string
+}{
+	{"ParseError", ErrParse, "parse error"},
+	{"InvalidRequest", ErrInvalidRequest, "invalid request"},
+	{"MethodNotFound", ErrNoMethod, "method not found"},
+	{"InvalidParams", ErrBadParams, "invalid params"},
+	{"InternalError", ErrInternal, "internal error"},
+	{"ServerError", ErrServer, "server error"},
+	{"Timeout", ErrTimeout, "request timeout"},
+	{"Session", ErrSession, "invalid or expired envelope session"},
+	{"Unauthorized", ErrAuthorization, "unauthorized"},
+	{"Forbidden", ErrForbidden, "forbidden"},
+	{"TooManyRequests", ErrTooManyRequests, "too many requests"},
+}
+
+type (
+	// OpenRPCDocument ... https://spec.open-rpc.org
+	OpenRPCDocument struct {
+		OpenRPC    string            `json:"openrpc"`
+		Info       OpenRPCInfo       `json:"info"`
+		Methods    []*OpenRPCMethod  `json:"methods"`
+		Components OpenRPCComponents `json:"components"`
+	}
+	// OpenRPCInfo ...
+	OpenRPCInfo struct {
+		Title       string `json:"title"`
+		Version     string `json:"version"`
+		Description string `json:"description,omitempty"`
+	}
+	// OpenRPCMethod ...
+	OpenRPCMethod struct {
+		Name           string                      `json:"name"`
+		Description    string                      `json:"description,omitempty"`
+		Deprecated     bool                        `json:"deprecated,omitempty"`
+		ParamStructure string                      `json:"paramStructure,omitempty"`
+		Params         []*OpenRPCContentDescriptor `json:"params"`
+		Result         *OpenRPCContentDescriptor   `json:"result"`
+		Errors         []*OpenRPCError             `json:"errors,omitempty"`
+	}
+	// OpenRPCContentDescriptor ...
+	OpenRPCContentDescriptor struct {
+		Name     string      `json:"name"`
+		Required bool        `json:"required,omitempty"`
+		Schema   *JSONSchema `json:"schema"`
+	}
+	// OpenRPCError ...
+	OpenRPCError struct {
+		Ref     string    `json:"$ref,omitempty"`
+		Code    ErrorCode `json:"code,omitempty"`
+		Message string    `json:"message,omitempty"`
+	}
+	// OpenRPCComponents ...
+	OpenRPCComponents struct {
+		Schemas map[string]*JSONSchema   `json:"schemas,omitempty"`
+		Errors  map[string]*OpenRPCError `json:"errors,omitempty"`
+	}
+	// JSONSchema ... a subset of JSON Schema draft 7
+	JSONSchema struct {
+		Ref                  string                 `json:"$ref,omitempty"`
+		Type                 string                 `json:"type,omitempty"`
+		Format               string                 `json:"format,omitempty"`
+		Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+		Required             []string               `json:"required,omitempty"`
+		Items                *JSONSchema            `json:"items,omitempty"`
+		AdditionalProperties *JSONSchema            `json:"additionalProperties,omitempty"`
+		Enum                 []interface{}          `json:"enum,omitempty"`
+		Minimum              *float64               `json:"minimum,omitempty"`
+		Maximum              *float64               `json:"maximum,omitempty"`
+		ExclusiveMinimum     *float64               `json:"exclusiveMinimum,omitempty"`
+		ExclusiveMaximum     *float64               `json:"exclusiveMaximum,omitempty"`
+		MinLength            *int                   `json:"minLength,omitempty"`
+		MaxLength            *int                   `json:"maxLength,omitempty"`
+		MinItems             *int                   `json:"minItems,omitempty"`
+		MaxItems             *int                   `json:"maxItems,omitempty"`
+	}
+)
+
+// rpcService the built-in service of the rpc namespace
+type rpcService struct{ s *server }
+
+// Discover returns the OpenRPC document of the registered services
+func (r *rpcService) Discover() *OpenRPCDocument { return r.s.Discover() }
+
+// isBuiltin the receiver is the built-in service of the rpc namespace
+func isBuiltin(receiver interface{}) bool {
+	_, ok := receiver.(*rpcService)
+	return ok
+}
+
+// DiscoverHandler serves the OpenRPC document over HTTP
+func DiscoverHandler(s RPCServer) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_, _ = w.Write(JsMarshal(s.Discover()))
+	})
+}
+
+// Discover returns the OpenRPC document of the registered services
+func (s *server) Discover() *OpenRPCDocument {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	doc := &OpenRPCDocument{
+		OpenRPC:    openRPCVersion,
+		Info:       OpenRPCInfo{Title: "j2rpc", Version: "1.0.0"},
+		Methods:    make([]*OpenRPCMethod, 0),
+		Components: OpenRPCComponents{Errors: make(map[string]*OpenRPCError)},
+	}
+	if s.opt.OpenRPCInfo != nil {
+		doc.Info = *s.opt.OpenRPCInfo
+	}
+	errRefs := make([]*OpenRPCError, 0, len(declaredErrors))
+	for _, de := range declaredErrors {
+		doc.Components.Errors[de.name] = &OpenRPCError{Code: de.code, Message: de.msg}
+		errRefs = append(errRefs, &OpenRPCError{Ref: errorRefRoot + de.name})
+	}
+	gen := newSchemaGenerator()
+	for _, svs := range s.services {
+		if isBuiltin(svs.receiver) {
+			continue
+		}
+		for name, cbk := range svs.callbacks {
+			if cbk.meta != nil && cbk.meta.Hidden {
+				continue
+			}
+			method := cbk.openRPCMethod(gen, svs.name+splitMethodSeparator+name)
+			method.Errors = errRefs
+			if len(svs.deprecated) > 0 {
+				method.Deprecated, method.Description = true, svs.deprecated
+			}
+			doc.Methods = append(doc.Methods, method)
+		}
+	}
+	sort.Slice(doc.Methods, func(i, j int) bool { return doc.Methods[i].Name < doc.Methods[j].Name })
+	doc.Components.Schemas = gen.defs
+	return doc
+}
+
+// openRPCMethod ...
+func (c *callback) openRPCMethod(gen *schemaGenerator, name string) *OpenRPCMethod {
+	m := &OpenRPCMethod{
+		Name:           name,
+		ParamStructure: "by-position",
+		Params:         make([]*OpenRPCContentDescriptor, 0, len(c.argTypes)),
+	}
+	if len(c.argNames) > 0 || (len(c.argTypes) == 1 && IndirectType(c.argTypes[0]).Kind() == reflect.Struct) {
+		m.ParamStructure = "either"
+	}
+	for i, typ := range c.argTypes {
+		pName := fmt.Sprintf("arg%d", i)
+		if i < len(c.argNames) {
+			pName = c.argNames[i]
+		}
+		m.Params = append(m.Params, &OpenRPCContentDescriptor{
+			Name:     pName,
+			Required: typ.Kind() != reflect.Ptr,
+			Schema:   gen.schema(typ),
+		})
+	}
+	if c.meta != nil {
+		m.Deprecated = c.meta.Deprecated
+	}
+	m.Result = &OpenRPCContentDescriptor{Name: "result", Schema: &JSONSchema{Type: "null"}}
+	if fnt := c.fn.Type(); fnt.NumOut() > 0 && c.errPos != 0 {
+		m.Result.Schema = gen.schema(fnt.Out(0))
+	}
+	return m
+}
+
+// schemaGenerator builds JSON schemas of go types, named structs are put in defs and referenced
+type schemaGenerator struct {
+	defs  map[string]*JSONSchema
+	names map[reflect.Type]string
+}
+
+func newSchemaGenerator() *schemaGenerator {
+	return &schemaGenerator{defs: make(map[string]*JSONSchema), names: make(map[reflect.Type]string)}
+}
+
+// defName returns a unique component name of the struct type
+func (g *schemaGenerator) defName(typ reflect.Type) string {
+	name := typ.Name()
+	for t, n := range g.names {
+		if n == name && t != typ {
+			name = strings.ReplaceAll(typ.String(), ".", "_")
+			break
+		}
+	}
+	return name
+}
+
+func (g *schemaGenerator) schema(typ reflect.Type) *JSONSchema {
+	typ = IndirectType(typ)
+	switch {
+	case typ == timeType, typ.Kind() == reflect.Struct && typ.ConvertibleTo(timeType):
+		return &JSONSchema{Type: "string", Format: "date-time"}
+	case typ == rawMessageType:
+		return &JSONSchema{}
+	case reflect.PointerTo(typ).Implements(jsonMarshalerType) || typ.Implements(jsonMarshalerType):
+		return &JSONSchema{}
+	case reflect.PointerTo(typ).Implements(textMarshalerType) || typ.Implements(textMarshalerType):
+		return &JSONSchema{Type: "string"}
+	}
+	switch typ.Kind() {
+	case reflect.Bool:
+		return &JSONSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &JSONSchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &JSONSchema{Type: "number"}
+	case reflect.String:
+		return &JSONSchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if typ.Elem().Kind() == reflect.Uint8 {
+			return &JSONSchema{Type: "string", Format: "byte"}
+		}
+		return &JSONSchema{Type: "array", Items: g.schema(typ.Elem())}
+	case reflect.Map:
+		return &JSONSchema{Type: "object", AdditionalProperties: g.schema(typ.Elem())}
+	case reflect.Struct:
+		if typ.Name() == "" {
+			return g.structSchema(typ)
+		}
+		if name, ok := g.names[typ]; ok {
+			return &JSONSchema{Ref: schemaRefRoot + name}
+		}
+		name := g.defName(typ)
+		// named before building fields, so recursive types resolve to the ref
+		g.names[typ] = name
+		g.defs[name] = g.structSchema(typ)
+		return &JSONSchema{Ref: schemaRefRoot + name}
+	}
+	return &JSONSchema{}
+}
+
+func (g *schemaGenerator) structSchema(typ reflect.Type) *JSONSchema {
+	sc := &JSONSchema{Type: "object", Properties: make(map[string]*JSONSchema)}
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" && IndirectType(field.Type).Kind() == reflect.Struct {
+			embedded := g.structSchema(IndirectType(field.Type))
+			for k, v := range embedded.Properties {
+				sc.Properties[k] = v
+			}
+			sc.Required = append(sc.Required, embedded.Required...)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fs := g.schema(field.Type)
+		if strings.Contains(opts, "string") && fs.Ref == "" {
+			fs = &JSONSchema{Type: "string"}
+		}
+		if applyValidateTag(fs, IndirectType(field.Type).Kind(), field.Tag.Get("validate")) {
+			sc.Required = append(sc.Required, name)
+		}
+		sc.Properties[name] = fs
+	}
+	return sc
+}
+
+// applyValidateTag maps the validate tag rules to schema constraints, returns whether the field is required
+func applyValidateTag(sc *JSONSchema, kind reflect.Kind, tag string) (required bool) {
+	if tag == "" || sc.Ref != "" {
+		return tag != "" && hasValidateRule(tag, "required")
+	}
+	for _, rule := range strings.Split(tag, ",") {
+		key, val, _ := strings.Cut(rule, "=")
+		switch key {
+		case "dive":
+			return
+		case "required":
+			required = true
+		case "min", "gte":
+			setLowerBound(sc, kind, val, false)
+		case "max", "lte":
+			setUpperBound(sc, kind, val, false)
+		case "gt":
+			setLowerBound(sc, kind, val, true)
+		case "lt":
+			setUpperBound(sc, kind, val, true)
+		case "len":
+			setLowerBound(sc, kind, val, false)
+			setUpperBound(sc, kind, val, false)
+		case "oneof":
+			for _, v := range strings.Fields(val) {
+				if f, err := strconv.ParseFloat(v, 64); err == nil && sc.Type != "string" {
+					sc.Enum = append(sc.Enum, f)
+					continue
+				}
+				sc.Enum = append(sc.Enum, v)
+			}
+		case "email":
+			sc.Format = "email"
+		case "url", "uri":
+			sc.Format = "uri"
+		case "uuid", "uuid4":
+			sc.Format = "uuid"
+		case "ipv4":
+			sc.Format = "ipv4"
+		case "ipv6":
+			sc.Format = "ipv6"
+		case "datetime":
+			sc.Format = "date-time"
+		}
+	}
+	return
+}
+
+func hasValidateRule(tag, rule string) bool {
+	for _, r := range strings.Split(tag, ",") {
+		if r == rule {
+			return true
+		}
+	}
+	return false
+}
+
+func setLowerBound(sc *JSONSchema, kind reflect.Kind, val string, exclusive bool) {
+	f, err := strconv.ParseFloat(val, 64)
+	if err != nil {
+		return
+	}
+	n := int(f)
+	if exclusive {
+		n++
+	}
+	switch sc.Type {
+	case "integer", "number":
+		if exclusive {
+			sc.ExclusiveMinimum = &f
+			return
+		}
+		sc.Minimum = &f
+	case "string":
+		if kind == reflect.String {
+			sc.MinLength = &n
+		}
+	case "array":
+		sc.MinItems = &n
+	}
+}
+
+func setUpperBound(sc *JSONSchema, kind reflect.Kind, val string, exclusive bool) {
+	f, err := strconv.ParseFloat(val, 64)
+	if err != nil {
+		return
+	}
+	n := int(f)
+	if exclusive {
+		n--
+	}
+	switch sc.Type {
+	case "integer", "number":
+		if exclusive {
+			sc.ExclusiveMaximum = &f
+			return
+		}
+		sc.Maximum = &f
+	case "string":
+		if kind == reflect.String {
+			sc.MaxLength = &n
+		}
+	case "array":
+		sc.MaxItems = &n
+	}
+}
@@ -0,0 +1,70 @@
+package j2rpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// appRPCService an rpc namespace of the application
+type appRPCService struct{}
+
+func (appRPCService) Ping(_ context.Context) (string, error) { return "pong", nil }
+
+func TestDiscover(t *testing.T) {
+	tests := []struct {
+		name   string
+		enable bool
+		// appRPC the application registers its own rpc namespace
+		appRPC bool
+		// discover the error code of rpc.discover, 0 if it returns the document
+		discover ErrorCode
+		methods  []string
+	}{
+		{"disabled", false, false, ErrNoMethod, []string{"calc.add", "calc.fail", "calc.notice"}},
+		{"enabled", true, false, 0, []string{"calc.add", "calc.fail", "calc.notice"}},
+		{"application rpc", true, true, ErrNoMethod, []string{"calc.add", "calc.fail", "calc.notice", "rpc.ping"}},
+		{"application rpc disabled", false, true, ErrNoMethod, []string{"calc.add", "calc.fail", "calc.notice", "rpc.ping"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New(&Option{SnakeNamespace: true, EnableDiscover: tt.enable})
+			s.Register(new(clientService), "calc")
+			if tt.appRPC {
+				require.NotPanics(t, func() { s.Register(appRPCService{}, DiscoverNamespace) })
+			}
+			require.Panics(t, func() { s.Register(new(clientService), "calc") })
+
+			doc := s.Discover()
+			methods := make([]string, 0, len(doc.Methods))
+			for _, m := range doc.Methods {
+				methods = append(methods, m.Name)
+			}
+			sort.Strings(methods)
+			assert.Equal(t, tt.methods, methods)
+
+			w := httptest.NewRecorder()
+			body := `{"jsonrpc":"2.0","id":1,"method":"rpc.discover","params":[]}`
+			s.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+			var resp struct {
+				Result *OpenRPCDocument `json:"result"`
+				Error  *Error           `json:"error"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp), w.Body.String())
+			if tt.discover != 0 {
+				require.NotNil(t, resp.Error)
+				assert.Equal(t, tt.discover, resp.Error.Code)
+				return
+			}
+			require.Nil(t, resp.Error)
+			assert.Len(t, resp.Result.Methods, len(tt.methods))
+		})
+	}
+}
@@ -3,9 +3,13 @@ package j2rpc
 import (
 	"bytes"
 	"fmt"
+	"net/http"
 	"strings"
+	"time"
 
 	"github.com/andeya/goutil"
+
+	"github.com/atcharles/glibs/util"
 )
 
 // SnakeOption ...
@@ -23,6 +27,68 @@ type Option struct {
 	Logger         LevelLogger
 	//CryptoKey  request/response crypto key, default is nil (no crypto)
 	CryptoKey string
+	//BatchConcurrency max number of batch elements handled concurrently, default is 1 (sequential)
+	BatchConcurrency int
+	//BatchLimit max number of elements in a batch request, default is 0 (no limit)
+	BatchLimit int
+	//EnableDiscover registers the built-in rpc.discover method, default is false.
+	//An rpc namespace registered by the application takes its place
+	EnableDiscover bool
+	//OpenRPCInfo info of the OpenRPC document returned by rpc.discover
+	OpenRPCInfo *OpenRPCInfo
+	//Timeouts execution time limits of callbacks, key is a namespace or a full method name (namespace.method)
+	Timeouts map[string]time.Duration
+	//ErrorRegistry maps the errors to JSON-RPC errors, default is DefaultErrorRegistry
+	ErrorRegistry *ErrorRegistry
+	//Validator validates the struct arguments with validate tags, default is util.ValidatorInc
+	Validator *util.Validator
+	//DisableValidation disables the validation of arguments
+	DisableValidation bool
+	//UserResolver resolves the caller checked by MethodMeta if it's not set by Context.SetUser
+	UserResolver func(c *Context) interface{}
+	//Envelope enables the envelope encryption by ECIES and AES-GCM session keys instead of CryptoKey
+	Envelope *EnvelopeOption
+	//DefaultVersions the version of the calls without version, key is the namespace, default is the oldest version
+	DefaultVersions map[string]string
+	//DeprecatedNamespaces the warnings of the deprecated namespaces, key is the namespace with version, e.g. user@1
+	DeprecatedNamespaces map[string]string
+	//Websocket heartbeats and limits of the websocket connections, default is DefaultWebsocketOption
+	Websocket *WebsocketOption
+}
+
+// WebsocketOption ...
+type WebsocketOption struct {
+	//PingInterval interval of the heartbeat pings
+	PingInterval time.Duration
+	//PongWait the connection is closed if no message or pong is received within it, must be greater than PingInterval
+	PongWait time.Duration
+	//WriteWait time limit of writing a message, a client too slow to read is disconnected
+	WriteWait time.Duration
+	//MaxMessageSize max size in bytes of a received message
+	MaxMessageSize int64
+	//MaxConcurrentCalls max number of calls of a connection handled concurrently.
+	//All calls of a connection share the context of the upgrade request (e.g. the iris.Context),
+	//the middlewares must be safe for concurrent use to set it greater than 1.
+	MaxConcurrentCalls int
+	//MaxPendingCalls max number of received calls waiting to be handled, the others are rejected
+	MaxPendingCalls int
+	//MaxSubscriptions max number of subscriptions of a connection
+	MaxSubscriptions int
+	//MaxConnections max number of connections of the server, 0 is no limit
+	MaxConnections int
+	//CheckOrigin see websocket.Upgrader.CheckOrigin, nil allows the same origin only
+	CheckOrigin func(r *http.Request) bool
+}
+
+// DefaultWebsocketOption ...
+var DefaultWebsocketOption = &WebsocketOption{
+	PingInterval:       time.Second * 30,
+	PongWait:           time.Second * 60,
+	WriteWait:          time.Second * 10,
+	MaxMessageSize:     1 << 20,
+	MaxConcurrentCalls: 1,
+	MaxPendingCalls:    64,
+	MaxSubscriptions:   64,
 }
 
 func (o *Option) ParseRPCBody(body []byte, isDecrypt bool) (_ []byte, err error) {
@@ -92,3 +158,60 @@ func ParseHandlerCrypto(str string) *HandlerCrypto {
 	}
 	return &HandlerCrypto{Key: str, UseBase64: true}
 }
+
+// bodyParser the bodyParser of CryptoKey, nil if there's no crypto or the envelope encryption is enabled
+func (o *Option) bodyParser() bodyParser {
+	if o.Envelope != nil {
+		return nil
+	}
+	if co := ParseHandlerCrypto(o.CryptoKey); co == nil || len(co.Key) < 16 {
+		return nil
+	}
+	return o.ParseRPCBody
+}
+
+// batchConcurrency ...
+func (o *Option) batchConcurrency() int {
+	if o.BatchConcurrency < 1 {
+		return 1
+	}
+	return o.BatchConcurrency
+}
+
+// websocket returns the websocket option, the zero values are replaced by the defaults
+func (o *Option) websocket() WebsocketOption {
+	if o.Websocket == nil {
+		return *DefaultWebsocketOption
+	}
+	opt, def := *o.Websocket, DefaultWebsocketOption
+	if opt.PingInterval <= 0 {
+		opt.PingInterval = def.PingInterval
+	}
+	if opt.PongWait <= opt.PingInterval {
+		opt.PongWait = opt.PingInterval * 2
+	}
+	if opt.WriteWait <= 0 {
+		opt.WriteWait = def.WriteWait
+	}
+	if opt.MaxMessageSize <= 0 {
+		opt.MaxMessageSize = def.MaxMessageSize
+	}
+	if opt.MaxConcurrentCalls < 1 {
+		opt.MaxConcurrentCalls = def.MaxConcurrentCalls
+	}
+	if opt.MaxPendingCalls < 1 {
+		opt.MaxPendingCalls = def.MaxPendingCalls
+	}
+	if opt.MaxSubscriptions < 1 {
+		opt.MaxSubscriptions = def.MaxSubscriptions
+	}
+	return opt
+}
+
+// validator ...
+func (o *Option) validator() *util.Validator {
+	if o.Validator == nil {
+		return util.ValidatorInc
+	}
+	return o.Validator
+}
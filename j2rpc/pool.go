@@ -1,9 +1,15 @@
 package j2rpc
 
 import (
+	"bytes"
 	"sync"
 )
 
+// maxPooledBufferSize the larger buffers are dropped instead of being put back to the pool
+const maxPooledBufferSize = 1 << 20
+
+var bufferPool = sync.Pool{New: func() interface{} { return new(bytes.Buffer) }}
+
 type ContextPool struct {
 	*sync.Pool
 }
@@ -16,9 +22,32 @@ func (p *ContextPool) Acquire() *Context {
 // Release ...
 func (p *ContextPool) Release(c *Context) {
 	c.msg.empty()
+	c.Body = nil
+	c.args = nil
+	c.result = nil
+	if c.buf != nil {
+		releaseBuffer(c.buf)
+		c.buf = nil
+	}
+	c.Store.Range(func(key, _ interface{}) bool {
+		c.Store.Delete(key)
+		return true
+	})
 	p.Pool.Put(c)
 }
 
 func newContextPool(newFunc func() interface{}) *ContextPool {
 	return &ContextPool{Pool: &sync.Pool{New: newFunc}}
 }
+
+// acquireBuffer ...
+func acquireBuffer() *bytes.Buffer { return bufferPool.Get().(*bytes.Buffer) }
+
+// releaseBuffer ...
+func releaseBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBufferSize {
+		return
+	}
+	buf.Reset()
+	bufferPool.Put(buf)
+}
@@ -18,6 +18,14 @@ func FuncNameFull(fn interface{}) string {
 	return runtime.FuncForPC(reflect.ValueOf(fn).Pointer()).Name()
 }
 
+// IndirectType ...指针指向的类型
+func IndirectType(typ reflect.Type) reflect.Type {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	return typ
+}
+
 // JSONExchange ...使用json copy对象
 func JSONExchange(dst interface{}, src interface{}) error {
 	b, err := json.Marshal(src)
@@ -0,0 +1,83 @@
+package j2rpc
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type (
+	benchService struct{}
+
+	benchItem struct {
+		ID    int               `json:"id"`
+		Name  string            `json:"name"`
+		Tags  []string          `json:"tags"`
+		Attrs map[string]string `json:"attrs"`
+	}
+)
+
+func (benchService) Echo(_ context.Context, s string) (string, error) { return s, nil }
+
+func (benchService) Items(_ context.Context, items []benchItem) ([]benchItem, error) {
+	return items, nil
+}
+
+func newBenchServer(b *testing.B) RPCServer {
+	b.Helper()
+	s := New(&Option{SnakeNamespace: true})
+	s.Register(benchService{}, "bench")
+	return s
+}
+
+// benchItems the params of bench.items with n items
+func benchItems(n int) string {
+	var sb strings.Builder
+	sb.WriteString("[[")
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		fmt.Fprintf(&sb, `{"id":%d,"name":"item-%d","tags":["a","b","c"],"attrs":{"k1":"v1","k2":"v2"}}`, i, i)
+	}
+	sb.WriteString("]]")
+	return sb.String()
+}
+
+// batchBody a batch of n copies of the call
+func batchBody(call string, n int) string {
+	return "[" + strings.TrimSuffix(strings.Repeat(call+",", n), ",") + "]"
+}
+
+func runHandlerBench(b *testing.B, body string) {
+	s := newBenchServer(b)
+	bts := []byte(body)
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(bts)))
+	if w.Code != http.StatusOK || bytes.Contains(w.Body.Bytes(), []byte(`"error"`)) {
+		b.Fatalf("unexpected response %d: %s", w.Code, w.Body.String())
+	}
+	b.ReportAllocs()
+	b.SetBytes(int64(len(bts)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(bts)))
+	}
+}
+
+func BenchmarkHandlerSmall(b *testing.B) {
+	call := `{"jsonrpc":"2.0","id":1,"method":"bench.echo","params":["hello"]}`
+	b.Run("single", func(b *testing.B) { runHandlerBench(b, call) })
+	b.Run("batch", func(b *testing.B) { runHandlerBench(b, batchBody(call, 10)) })
+}
+
+func BenchmarkHandlerLarge(b *testing.B) {
+	call := `{"jsonrpc":"2.0","id":1,"method":"bench.items","params":` + benchItems(500) + `}`
+	b.Run("single", func(b *testing.B) { runHandlerBench(b, call) })
+	b.Run("batch", func(b *testing.B) { runHandlerBench(b, batchBody(call, 10)) })
+}
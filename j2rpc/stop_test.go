@@ -0,0 +1,74 @@
+package j2rpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stopService Wait blocks until release
+type stopService struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s *stopService) Wait(_ context.Context) (string, error) {
+	s.started <- struct{}{}
+	<-s.release
+	return "done", nil
+}
+
+func TestStopDrain(t *testing.T) {
+	svc := &stopService{started: make(chan struct{}), release: make(chan struct{})}
+	s := New(&Option{SnakeNamespace: true})
+	s.Register(svc, "stop")
+	s.Register(new(clientService), "calc")
+	post := func(method, params string) *httptest.ResponseRecorder {
+		body := `{"jsonrpc":"2.0","id":1,"method":"` + method + `","params":` + params + `}`
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+		return w
+	}
+
+	pending := make(chan *httptest.ResponseRecorder)
+	go func() { pending <- post("stop.wait", "[]") }()
+	<-svc.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, s.Stop(ctx), context.DeadlineExceeded)
+
+	// the new requests are rejected while stopping
+	w := post("calc.add", "[1,2]")
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, strconv.Itoa(stopRetryAfter), w.Header().Get("Retry-After"))
+	msg := new(RPCMessage)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), msg))
+	require.NotNil(t, msg.Error)
+	assert.Equal(t, ErrServer, msg.Error.Code)
+	_, rpcErr := s.Invoke(context.Background(), "calc.add", []int{1, 2})
+	require.NotNil(t, rpcErr)
+	assert.Equal(t, ErrServer, rpcErr.Code)
+
+	// the pending request is finished before Stop returns
+	stopped := make(chan error)
+	go func() { stopped <- s.Stop(context.Background()) }()
+	select {
+	case err := <-stopped:
+		require.Fail(t, "Stop returned with a pending request", "%v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(svc.release)
+	w = <-pending
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"done"`)
+	require.NoError(t, <-stopped)
+}
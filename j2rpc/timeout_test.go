@@ -0,0 +1,124 @@
+package j2rpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type timeoutService struct {
+	release  chan struct{}
+	finished atomic.Int32
+	// err the error of the context seen by slow after the release
+	err atomic.Value
+}
+
+func (*timeoutService) J2rpcTimeout() map[string]time.Duration {
+	return map[string]time.Duration{"": time.Second, "Slow": 20 * time.Millisecond}
+}
+
+// Slow outlives its timeout until the release, then reads and writes its context
+func (s *timeoutService) Slow(c *Context, n int) (int, error) {
+	<-s.release
+	s.err.Store(c.Err())
+	c.Store.Store("slow", n)
+	_ = c.Msg().Method + string(c.Body)
+	s.finished.Add(1)
+	return n, nil
+}
+
+func (*timeoutService) Fast(c *Context, n int) (int, error) {
+	c.Store.Store("fast", n)
+	return n, nil
+}
+
+func TestCallTimeout(t *testing.T) {
+	svc := &timeoutService{release: make(chan struct{})}
+	s := New(&Option{SnakeNamespace: true})
+	s.Register(svc, "to")
+	var stored sync.Map
+	s.OnComplete(func(c *Context) {
+		if v, ok := c.Store.Load("fast"); ok {
+			stored.Store(string(c.Msg().ID), v)
+		}
+	})
+	call := func(id int, method string) *RPCMessage {
+		body := `{"jsonrpc":"2.0","id":` + strconv.Itoa(id) + `,"method":"` + method + `","params":[` + strconv.Itoa(id) + `]}`
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+		msg := new(RPCMessage)
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), msg), w.Body.String())
+		return msg
+	}
+
+	slow := call(1, "to.slow")
+	require.NotNil(t, slow.Error)
+	assert.Equal(t, ErrTimeout, slow.Error.Code)
+
+	// the contexts are reused by other calls while slow is still running
+	var wg sync.WaitGroup
+	for i := 2; i < 22; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			msg := call(i, "to.fast")
+			assert.Nil(t, msg.Error)
+			assert.Equal(t, strconv.Itoa(i), string(msg.Result))
+		}(i)
+	}
+	wg.Wait()
+	// the Store written by a method in time is seen by the complete handlers
+	for i := 2; i < 22; i++ {
+		v, ok := stored.Load(strconv.Itoa(i))
+		assert.True(t, ok)
+		assert.Equal(t, i, v)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, s.Stop(ctx), context.DeadlineExceeded, "slow is pending")
+
+	close(svc.release)
+	ctx, cancel = context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	require.NoError(t, s.Stop(ctx))
+	assert.Equal(t, int32(1), svc.finished.Load())
+	assert.Equal(t, context.DeadlineExceeded, svc.err.Load())
+}
+
+func TestCallbackTimeout(t *testing.T) {
+	tests := []struct {
+		name      string
+		namespace string
+		timeouts  map[string]time.Duration
+		// want the timeouts of slow and fast
+		slow, fast time.Duration
+	}{
+		{"receiver", "to", nil, 20 * time.Millisecond, time.Second},
+		{"option namespace", "to", map[string]time.Duration{"to": 3 * time.Second}, 20 * time.Millisecond, 3 * time.Second},
+		{"option method", "to", map[string]time.Duration{"to.slow": 5 * time.Second}, 5 * time.Second, time.Second},
+		{"version", "to@2", map[string]time.Duration{"to": 3 * time.Second}, 20 * time.Millisecond, 3 * time.Second},
+		{"namespace with version", "to@2", map[string]time.Duration{"to@2": 4 * time.Second, "to": 3 * time.Second}, 20 * time.Millisecond, 4 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New(&Option{SnakeNamespace: true, Timeouts: tt.timeouts}).(*server)
+			s.Register(new(timeoutService), tt.namespace)
+			for method, want := range map[string]time.Duration{"slow": tt.slow, "fast": tt.fast} {
+				cbk := s.services[tt.namespace].callbacks[method]
+				cbk.providerMethod = tt.namespace + splitMethodSeparator + method
+				assert.Equal(t, want, cbk.timeout(), method)
+			}
+		})
+	}
+}
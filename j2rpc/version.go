@@ -0,0 +1,128 @@
+package j2rpc
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// VersionHeader the version of the namespace called by a method without version,
+	// and the version which served the call in the response
+	VersionHeader = "X-J2rpc-Version"
+
+	versionSeparator = "@"
+
+	// deprecationLogInterval a deprecated namespace is logged once in it
+	deprecationLogInterval = time.Minute
+)
+
+// deprecationLogs the last time of the warning of each deprecated namespace
+type deprecationLogs struct {
+	mu   sync.Mutex
+	last map[string]time.Time
+}
+
+// allow ...
+func (d *deprecationLogs) allow(namespace string) bool {
+	now := time.Now()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.last == nil {
+		d.last = make(map[string]time.Time)
+	}
+	if now.Sub(d.last[namespace]) < deprecationLogInterval {
+		return false
+	}
+	d.last[namespace] = now
+	return true
+}
+
+// defaultVersion the version of the namespace called without version: Option.DefaultVersions,
+// otherwise the oldest one, as the clients which send no version are the old ones
+func (s *server) defaultVersion(namespace string) (string, bool) {
+	if v, ok := s.opt.DefaultVersions[namespace]; ok {
+		return v, true
+	}
+	var oldest string
+	var found bool
+	for _, svs := range s.services {
+		if svs.namespace != namespace || len(svs.version) == 0 {
+			continue
+		}
+		if !found || compareVersion(svs.version, oldest) < 0 {
+			oldest, found = svs.version, true
+		}
+	}
+	return oldest, found
+}
+
+// lookupService finds the service of the namespace, which may have a version. A namespace without version
+// is the version of VersionHeader if the namespace has it, the namespace registered without version,
+// or the default version. So a client pinned to a version still calls the namespaces which don't have it.
+func (s *server) lookupService(r *http.Request, namespace string) (svs service, ok bool) {
+	if strings.Contains(namespace, versionSeparator) {
+		svs, ok = s.services[namespace]
+		return
+	}
+	if r != nil {
+		if v := strings.TrimSpace(r.Header.Get(VersionHeader)); len(v) > 0 {
+			if svs, ok = s.services[namespace+versionSeparator+v]; ok {
+				return
+			}
+		}
+	}
+	if svs, ok = s.services[namespace]; ok {
+		return
+	}
+	if v, found := s.defaultVersion(namespace); found {
+		svs, ok = s.services[namespace+versionSeparator+v]
+	}
+	return
+}
+
+// versionCalled sets the version headers of the response, and warns the caller of a deprecated namespace
+func (s *server) versionCalled(c *Context, svs service) {
+	if len(svs.version) > 0 {
+		c.Writer().Header().Set(VersionHeader, svs.version)
+	}
+	if len(svs.deprecated) == 0 {
+		return
+	}
+	warning := fmt.Sprintf("namespace %s is deprecated: %s", svs.name, svs.deprecated)
+	c.Writer().Header().Set("Deprecation", "true")
+	c.Writer().Header().Set("Warning", "299 - "+strconv.Quote(warning))
+	if s.deprecations.allow(svs.name) {
+		s.Logger().Warnf("[Deprecated] %s, called by %s", warning, callerKey(c))
+	}
+}
+
+// compareVersion compares the dot separated versions by numbers, or by strings if they aren't numbers
+func compareVersion(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aErr := strconv.Atoi(as[i])
+		bn, bErr := strconv.Atoi(bs[i])
+		switch {
+		case aErr == nil && bErr == nil && an != bn:
+			if an < bn {
+				return -1
+			}
+			return 1
+		case (aErr != nil || bErr != nil) && as[i] != bs[i]:
+			return strings.Compare(as[i], bs[i])
+		}
+	}
+	return len(as) - len(bs)
+}
+
+// splitVersion splits "user@2" into "user" and "2"
+func splitVersion(name string) (namespace, version string) {
+	if i := strings.Index(name, versionSeparator); i >= 0 {
+		return name[:i], name[i+1:]
+	}
+	return name, ""
+}
@@ -0,0 +1,82 @@
+package j2rpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type (
+	userV1 struct{}
+	userV2 struct{}
+)
+
+func (userV1) Name(_ context.Context) (string, error) { return "v1", nil }
+
+func (userV2) Name(_ context.Context) (string, error) { return "v2", nil }
+
+func TestVersionLookup(t *testing.T) {
+	s := New(&Option{SnakeNamespace: true})
+	s.Register(userV1{}, "user@1")
+	s.Register(userV2{}, "user@2")
+	s.Register(new(clientService), "calc")
+	call := func(method, params string) string {
+		return `{"jsonrpc":"2.0","id":"` + method + `","method":"` + method + `","params":` + params + `}`
+	}
+	tests := []struct {
+		name    string
+		header  string
+		body    string
+		want    []string
+		version string
+	}{
+		{"default is the oldest", "", call("user.name", "[]"), []string{`"v1"`}, "1"},
+		{"pinned", "2", call("user.name", "[]"), []string{`"v2"`}, "2"},
+		{"explicit over pinned", "1", call("user@2.name", "[]"), []string{`"v2"`}, "2"},
+		{"pinned to a missing version", "9", call("user.name", "[]"), []string{`"v1"`}, "1"},
+		{"pinned calls unversioned", "2", call("calc.add", "[1,2]"), []string{"3"}, ""},
+		{
+			"pinned batch of versioned and unversioned", "2",
+			"[" + call("user.name", "[]") + "," + call("calc.add", "[1,2]") + "]",
+			[]string{`"v2"`, "3"}, "2",
+		},
+		{"unknown namespace", "2", call("none.name", "[]"), nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			if len(tt.header) > 0 {
+				r.Header.Set(VersionHeader, tt.header)
+			}
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, r)
+			require.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, tt.version, w.Header().Get(VersionHeader))
+			var messages []*RPCMessage
+			if body := w.Body.Bytes(); isBatchBody(body) {
+				require.NoError(t, json.Unmarshal(body, &messages))
+			} else {
+				msg := new(RPCMessage)
+				require.NoError(t, json.Unmarshal(body, msg))
+				messages = append(messages, msg)
+			}
+			if tt.want == nil {
+				require.Len(t, messages, 1)
+				require.NotNil(t, messages[0].Error)
+				assert.Equal(t, ErrNoMethod, messages[0].Error.Code)
+				return
+			}
+			require.Len(t, messages, len(tt.want))
+			for i, msg := range messages {
+				require.Nil(t, msg.Error, string(msg.ID))
+				assert.Equal(t, tt.want[i], string(msg.Result))
+			}
+		})
+	}
+}
@@ -0,0 +1,465 @@
+package j2rpc
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	subscribeSuffix    = "_subscribe"
+	unsubscribeSuffix  = "_unsubscribe"
+	subscriptionSuffix = "_subscription"
+)
+
+type (
+	// wsConn a websocket connection, the calls are handled by MaxConcurrentCalls workers,
+	// the messages are written by a single writer
+	wsConn struct {
+		s       *server
+		opt     WebsocketOption
+		conn    *websocket.Conn
+		parent  context.Context
+		request *http.Request
+		parse   bodyParser
+
+		ctx    context.Context
+		cancel context.CancelFunc
+		in     chan []byte
+		out    chan []byte
+		wg     sync.WaitGroup
+
+		mu   sync.Mutex
+		subs map[string]context.CancelFunc
+	}
+
+	// subscriptionResult the params of the notifications of a subscription
+	subscriptionResult struct {
+		ID     string          `json:"subscription"`
+		Result json.RawMessage `json:"result,omitempty"`
+	}
+)
+
+// WebsocketHandler upgrades the request to a websocket connection, which serves the JSON-RPC calls
+// (single and batch) through the middlewares and the callbacks like Handler, until it is closed.
+// All calls of the connection get ctx.Context, e.g. the iris.Context of the upgrade request, so the JWT
+// middlewares work as with HTTP.
+//
+// A method returning a channel is a subscription, it's subscribed by "<namespace>_subscribe" with the
+// params [method, args...] and returns the subscription id. The values received from the channel are
+// pushed as "<namespace>_subscription" notifications with the params {"subscription":id,"result":value}
+// until "<namespace>_unsubscribe" with the params [id], the channel is closed or the connection is closed.
+// The method should stop sending when its context argument is done.
+func (s *server) WebsocketHandler(ctx *Context) {
+	defer ctx.Release()
+	w, r := ctx.Writer(), ctx.Request()
+	if atomic.LoadInt32(&s.run) == 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(stopRetryAfter))
+		writeError(w, http.StatusServiceUnavailable, "server is stopping")
+		return
+	}
+	parse, err := s.requestBodyParser(r)
+	if err != nil {
+		writeJSONResponseStatus(w, nil, http.StatusUnauthorized, RPCError(err).output())
+		return
+	}
+	opt := s.Opt().websocket()
+	wc := &wsConn{
+		parse:   parse,
+		s:       s,
+		opt:     opt,
+		parent:  ctx.Context,
+		request: r,
+		in:      make(chan []byte, opt.MaxPendingCalls),
+		out:     make(chan []byte, opt.MaxPendingCalls),
+		subs:    make(map[string]context.CancelFunc),
+	}
+	wc.ctx, wc.cancel = context.WithCancel(ctx.Context)
+	defer wc.cancel()
+	if !s.addWebsocket(wc, opt.MaxConnections) {
+		writeError(w, http.StatusServiceUnavailable, "too many connections")
+		return
+	}
+	defer s.removeWebsocket(wc)
+	upgrader := websocket.Upgrader{CheckOrigin: opt.CheckOrigin}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// the upgrader has replied with the HTTP error
+		return
+	}
+	s.mutex.Lock()
+	wc.conn = conn
+	s.mutex.Unlock()
+	wc.serve()
+}
+
+// WebsocketHTTPHandler the http.Handler of the websocket connections of s
+func WebsocketHTTPHandler(s RPCServer) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.WebsocketHandler(s.GetContext().BeginWithContext(r.Context(), w, r))
+	})
+}
+
+// addWebsocket ...
+func (s *server) addWebsocket(wc *wsConn, limit int) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.websockets == nil {
+		s.websockets = make(map[*wsConn]struct{})
+	}
+	if limit > 0 && len(s.websockets) >= limit {
+		return false
+	}
+	s.websockets[wc] = struct{}{}
+	return true
+}
+
+// closeWebsockets sends a close message to the connections, they are closed by the clients or at PongWait
+func (s *server) closeWebsockets() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for wc := range s.websockets {
+		wc.goingAway("server is stopping")
+	}
+}
+
+// removeWebsocket ...
+func (s *server) removeWebsocket(wc *wsConn) {
+	s.mutex.Lock()
+	delete(s.websockets, wc)
+	s.mutex.Unlock()
+}
+
+// goingAway ...
+func (c *wsConn) goingAway(reason string) {
+	if c.conn == nil {
+		return
+	}
+	msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, reason)
+	_ = c.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(c.opt.WriteWait))
+}
+
+// serve runs until the connection is closed, and waits for the calls and the subscriptions,
+// which share the context of the upgrade request
+func (c *wsConn) serve() {
+	defer func() {
+		c.cancel()
+		_ = c.conn.Close()
+		c.wg.Wait()
+	}()
+	c.wg.Add(1 + c.opt.MaxConcurrentCalls)
+	go c.writeLoop()
+	for i := 0; i < c.opt.MaxConcurrentCalls; i++ {
+		go c.callLoop()
+	}
+	c.readLoop()
+}
+
+// readLoop ...
+func (c *wsConn) readLoop() {
+	c.conn.SetReadLimit(c.opt.MaxMessageSize)
+	_ = c.conn.SetReadDeadline(time.Now().Add(c.opt.PongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(c.opt.PongWait))
+	})
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				c.s.Logger().Debugf("[Websocket] %s: %s", c.request.RemoteAddr, err.Error())
+			}
+			return
+		}
+		_ = c.conn.SetReadDeadline(time.Now().Add(c.opt.PongWait))
+		select {
+		case c.in <- data:
+		default:
+			c.reject(data, NewError(ErrServer, "too many pending calls"))
+		}
+	}
+}
+
+// writeLoop writes the messages and the heartbeat pings, a failed write closes the connection
+func (c *wsConn) writeLoop() {
+	defer c.wg.Done()
+	ping := time.NewTicker(c.opt.PingInterval)
+	defer ping.Stop()
+	for {
+		var err error
+		select {
+		case <-c.ctx.Done():
+			return
+		case msg := <-c.out:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(c.opt.WriteWait))
+			err = c.conn.WriteMessage(websocket.TextMessage, msg)
+		case <-ping.C:
+			err = c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(c.opt.WriteWait))
+		}
+		if err != nil {
+			c.cancel()
+			_ = c.conn.Close()
+			return
+		}
+	}
+}
+
+// callLoop ...
+func (c *wsConn) callLoop() {
+	defer c.wg.Done()
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case data := <-c.in:
+			c.handleMessage(data)
+		}
+	}
+}
+
+// handleMessage ...
+func (c *wsConn) handleMessage(data []byte) {
+	atomic.AddInt64(&c.s.pending, 1)
+	defer atomic.AddInt64(&c.s.pending, -1)
+	if atomic.LoadInt32(&c.s.run) == 0 {
+		c.reject(data, NewError(ErrServer, "server is stopping", map[string]interface{}{"retry_after": stopRetryAfter}))
+		return
+	}
+	body, err := c.parse.decrypt(bytes.TrimSpace(data))
+	if err != nil {
+		c.send(RPCError(NewError(ErrParse, "请求错误")).output())
+		return
+	}
+	ctx := c.newContext(c.s.GetContext())
+	defer ctx.Release()
+	defer func() {
+		if p := recover(); p != nil {
+			c.send(RPCError(c.s.stack(p, ctx.Msg().Method)).output())
+		}
+	}()
+	if isBatchBody(body) {
+		if buf := c.s.handleBatch(ctx, body); buf != nil {
+			c.write(buf.Bytes())
+			releaseBuffer(buf)
+		}
+		return
+	}
+	if err = c.s.decode(ctx, body); err == nil {
+		switch head := ctx.Msg(); {
+		case strings.HasSuffix(head.Method, subscribeSuffix):
+			c.subscribe(head, body)
+			return
+		case strings.HasSuffix(head.Method, unsubscribeSuffix):
+			c.unsubscribe(head)
+			return
+		}
+		err = c.s.process(ctx)
+	}
+	if err != nil {
+		ctx.Msg().setError(err, c.s.Opt().ErrorRegistry)
+	}
+	if ctx.Msg().isNotification() {
+		c.s.complete(ctx)
+		c.s.logNotificationError(ctx.Msg(), err)
+		return
+	}
+	buf := c.s.encodeResponse(ctx)
+	defer releaseBuffer(buf)
+	c.s.complete(ctx)
+	c.write(buf.Bytes())
+}
+
+// newContext begins ctx with the context and the request of the connection
+func (c *wsConn) newContext(ctx *Context) *Context {
+	ctx.BeginWithContext(c.parent, &discardResponseWriter{header: make(http.Header)}, c.request)
+	ctx.fields = requestFields(c.request)
+	return ctx
+}
+
+// reject replies the error to the message, if it's not a notification
+func (c *wsConn) reject(data []byte, err *Error) {
+	msg := new(RPCMessage)
+	if body, e := c.parse.decrypt(bytes.TrimSpace(data)); e == nil {
+		_ = json.Unmarshal(body, msg)
+	}
+	if msg.isNotification() {
+		return
+	}
+	out := RPCError(err)
+	out.ID = msg.ID
+	c.send(out.output())
+}
+
+// send queues the message to the writer
+func (c *wsConn) send(val interface{}) {
+	bts, err := json.Marshal(val)
+	if err != nil {
+		c.s.Logger().Errorf("[Websocket] write error: %s", err.Error())
+		return
+	}
+	c.write(bts)
+}
+
+// write encrypts a copy of the encoded message and queues it to the writer
+func (c *wsConn) write(bts []byte) {
+	out, err := c.parse.encrypt(append([]byte(nil), bts...))
+	if err != nil {
+		c.s.Logger().Errorf("[Websocket] write error: %s", err.Error())
+		return
+	}
+	select {
+	case c.out <- out:
+	case <-c.ctx.Done():
+	}
+}
+
+// subscribe calls the method in the params, which returns a channel, and forwards its values
+func (c *wsConn) subscribe(head *RPCMessage, body []byte) {
+	namespace := strings.TrimSuffix(head.Method, subscribeSuffix)
+	var params []json.RawMessage
+	var method string
+	if json.Unmarshal(head.Params, &params) != nil || len(params) == 0 || json.Unmarshal(params[0], &method) != nil {
+		c.replyError(head, NewError(ErrBadParams, "the first param must be the subscription method"))
+		return
+	}
+	if c.subscriptions() >= c.opt.MaxSubscriptions {
+		c.replyError(head, NewError(ErrServer, "too many subscriptions"))
+		return
+	}
+	// the context of a subscription isn't pooled, it's used by the method after the call
+	ctx := c.newContext(newContext(c.s))
+	subCtx, cancel := context.WithCancel(c.ctx)
+	var id string
+	defer func() {
+		if len(id) == 0 {
+			cancel()
+		}
+	}()
+	ctx.subCtx = subCtx
+	ctx.Body = body
+	ctx.Store.Store(StartTimeKey, time.Now().UnixNano())
+	msg := ctx.Msg()
+	msg.ID, msg.Version, msg.Method = head.ID, head.Version, namespace+splitMethodSeparator+method
+	if len(params) > 1 {
+		msg.Params, _ = json.Marshal(params[1:])
+	}
+	res, err := c.s.callMessage(ctx)
+	ch := reflect.ValueOf(res)
+	if err == nil && (!ch.IsValid() || ch.Kind() != reflect.Chan || ch.Type().ChanDir()&reflect.RecvDir == 0) {
+		err = NewError(ErrInvalidRequest, "the method is not a subscription")
+	}
+	if err == nil {
+		id, err = c.addSubscription(cancel)
+	}
+	if err != nil {
+		msg.setError(err, c.s.Opt().ErrorRegistry)
+		c.s.complete(ctx)
+		if !msg.isNotification() {
+			c.send(msg.output())
+		}
+		return
+	}
+	msg.Result, _ = json.Marshal(id)
+	c.s.complete(ctx)
+	if !msg.isNotification() {
+		c.send(msg.output())
+	}
+	c.wg.Add(1)
+	go c.forward(subCtx, id, namespace+subscriptionSuffix, ch)
+}
+
+// forward pushes the values of the channel as notifications until the subscription is done
+func (c *wsConn) forward(ctx context.Context, id, method string, ch reflect.Value) {
+	defer c.wg.Done()
+	defer c.removeSubscription(id)
+	cases := []reflect.SelectCase{
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())},
+		{Dir: reflect.SelectRecv, Chan: ch},
+	}
+	for {
+		chosen, val, ok := reflect.Select(cases)
+		if chosen == 0 || !ok {
+			return
+		}
+		result, err := json.Marshal(val.Interface())
+		if err != nil {
+			c.s.Logger().Errorf("[Websocket] %s: %s", method, err.Error())
+			continue
+		}
+		params, _ := json.Marshal(&subscriptionResult{ID: id, Result: result})
+		c.send(&RPCMessage{Version: vsn, Method: method, Params: params})
+	}
+}
+
+// unsubscribe cancels the subscription of the id in the params
+func (c *wsConn) unsubscribe(head *RPCMessage) {
+	var params []string
+	if json.Unmarshal(head.Params, &params) != nil || len(params) == 0 {
+		c.replyError(head, NewError(ErrBadParams, "the first param must be the subscription id"))
+		return
+	}
+	if !c.removeSubscription(params[0]) {
+		c.replyError(head, NewError(ErrInvalidRequest, "subscription not found"))
+		return
+	}
+	if head.isNotification() {
+		return
+	}
+	msg := &RPCMessage{ID: head.ID, Result: json.RawMessage("true")}
+	c.send(msg.output())
+}
+
+// replyError ...
+func (c *wsConn) replyError(head *RPCMessage, err *Error) {
+	if head.isNotification() {
+		return
+	}
+	msg := RPCError(err)
+	msg.ID = head.ID
+	c.send(msg.output())
+}
+
+// addSubscription ...
+func (c *wsConn) addSubscription(cancel context.CancelFunc) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	id := "0x" + hex.EncodeToString(b)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.subs) >= c.opt.MaxSubscriptions {
+		return "", NewError(ErrServer, "too many subscriptions")
+	}
+	c.subs[id] = cancel
+	return id, nil
+}
+
+// removeSubscription cancels the subscription, returns false if it doesn't exist
+func (c *wsConn) removeSubscription(id string) bool {
+	c.mu.Lock()
+	cancel, ok := c.subs[id]
+	delete(c.subs, id)
+	c.mu.Unlock()
+	if ok {
+		cancel()
+	}
+	return ok
+}
+
+// subscriptions ...
+func (c *wsConn) subscriptions() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.subs)
+}
@@ -0,0 +1,120 @@
+package j2rpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// wsService Ticks sends n values, then waits for the end of the subscription
+type wsService struct {
+	clientService
+	stopped chan struct{}
+}
+
+func (s *wsService) Ticks(ctx context.Context, n int) (<-chan int, error) {
+	ch := make(chan int)
+	go func() {
+		defer close(s.stopped)
+		for i := 0; i < n; i++ {
+			select {
+			case ch <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+		<-ctx.Done()
+	}()
+	return ch, nil
+}
+
+func TestWebsocket(t *testing.T) {
+	svc := &wsService{stopped: make(chan struct{})}
+	s := New(&Option{SnakeNamespace: true})
+	s.Register(svc, "ws")
+	ts := httptest.NewServer(WebsocketHTTPHandler(s))
+	defer ts.Close()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http"), nil)
+	require.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+
+	send := func(body string) { require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(body))) }
+	read := func() json.RawMessage {
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+		_, data, err := conn.ReadMessage()
+		require.NoError(t, err)
+		return data
+	}
+	readMsg := func() *RPCMessage {
+		msg := new(RPCMessage)
+		data := read()
+		require.NoError(t, json.Unmarshal(data, msg), string(data))
+		return msg
+	}
+
+	// the notification has no response, the next message is the response of the call
+	send(`{"jsonrpc":"2.0","method":"ws.notice","params":[1]}`)
+	send(`{"jsonrpc":"2.0","id":1,"method":"ws.add","params":[1,2]}`)
+	msg := readMsg()
+	assert.Equal(t, "1", string(msg.ID))
+	assert.Equal(t, "3", string(msg.Result))
+	assert.Equal(t, int64(1), svc.notified.Load())
+
+	send(`[{"jsonrpc":"2.0","id":2,"method":"ws.add","params":[2,2]},{"jsonrpc":"2.0","id":3,"method":"ws.fail","params":[]}]`)
+	var batch []*RPCMessage
+	require.NoError(t, json.Unmarshal(read(), &batch))
+	require.Len(t, batch, 2)
+	assert.Equal(t, "4", string(batch[0].Result))
+	require.NotNil(t, batch[1].Error)
+	assert.Equal(t, ErrForbidden, batch[1].Error.Code)
+
+	send(`{"jsonrpc":"2.0","id":4,"method":"ws_subscribe","params":["add",1,2]}`)
+	msg = readMsg()
+	require.NotNil(t, msg.Error)
+	assert.Equal(t, ErrInvalidRequest, msg.Error.Code)
+
+	send(`{"jsonrpc":"2.0","id":5,"method":"ws_subscribe","params":["ticks",3]}`)
+	msg = readMsg()
+	require.Nil(t, msg.Error)
+	var id string
+	require.NoError(t, json.Unmarshal(msg.Result, &id))
+	require.NotEmpty(t, id)
+	for i := 0; i < 3; i++ {
+		msg = readMsg()
+		assert.Equal(t, "ws_subscription", msg.Method)
+		assert.Empty(t, msg.ID)
+		var params subscriptionResult
+		require.NoError(t, json.Unmarshal(msg.Params, &params))
+		assert.Equal(t, id, params.ID)
+		assert.Equal(t, json.RawMessage([]byte{byte('0' + i)}), params.Result)
+	}
+
+	send(`{"jsonrpc":"2.0","id":6,"method":"ws_unsubscribe","params":["` + id + `"]}`)
+	msg = readMsg()
+	assert.Equal(t, "6", string(msg.ID))
+	assert.Equal(t, "true", string(msg.Result))
+	select {
+	case <-svc.stopped:
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "the context of the subscription isn't done")
+	}
+	send(`{"jsonrpc":"2.0","id":7,"method":"ws_unsubscribe","params":["` + id + `"]}`)
+	msg = readMsg()
+	require.NotNil(t, msg.Error)
+	assert.Equal(t, ErrInvalidRequest, msg.Error.Code)
+
+	// Stop closes the connections
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, s.Stop(ctx))
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	_, _, err = conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), "%v", err)
+}
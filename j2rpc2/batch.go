@@ -0,0 +1,79 @@
+package j2rpc
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+)
+
+// batchWriter records the response of a batch element
+type batchWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *batchWriter) Header() http.Header { return b.header }
+
+func (b *batchWriter) Write(data []byte) (int, error) { return b.body.Write(data) }
+
+func (b *batchWriter) WriteHeader(status int) { b.status = status }
+
+// handleBatch runs every element of the batch through the handler chain, each with its own Context,
+// and writes the responses in request order
+func (s *server) handleBatch(parent *rpcContext, elements []RawMessage) {
+	if limit := s.option.BatchLimit; limit > 0 && len(elements) > limit {
+		parent.writeMessage(parent.wireMessage(&RPCMessage{
+			ID:      RawMessage("null"),
+			Version: "2.0",
+			Error:   NewError(ErrInvalidRequest, fmt.Sprintf("batch too large (%d>%d)", len(elements), limit)),
+		}))
+		return
+	}
+	responses := make([]interface{}, 0, len(elements))
+	for _, element := range elements {
+		if msg := s.handleBatchElement(parent, element); msg != nil {
+			responses = append(responses, msg)
+		}
+	}
+	if len(responses) == 0 {
+		parent.Writer().WriteHeader(http.StatusNoContent)
+		parent.SetWrote(true)
+		return
+	}
+	parent.writeMessage(responses)
+}
+
+// handleBatchElement returns the response of the element to encode by the response codec, nil for a notification.
+// The element's Context copies the values of the parent, and its response is recorded instead of written,
+// a response written by StopWriteStringStatus becomes an error with the status as code.
+func (s *server) handleBatchElement(parent *rpcContext, element RawMessage) interface{} {
+	msg, err := decodeMessage(parent.requestCodec(), element)
+	if err != nil {
+		return parent.wireMessage(&RPCMessage{ID: RawMessage("null"), Version: "2.0", Error: NewError(ErrInvalidRequest, err.Error())})
+	}
+	w := &batchWriter{header: make(http.Header), status: http.StatusOK}
+	c := newRpcContext(parent.Context, w, parent.req)
+	c.server = s
+	parent.RLock()
+	for key, val := range parent.store {
+		c.store[key] = val
+	}
+	parent.RUnlock()
+	c.store[BodyContextKey] = []byte(element)
+	c.SetMsg(msg)
+	c.AddHandler(s.getOrNewGroup("").Handlers()...)
+	c.Next()
+	if msg.isNotification() {
+		return nil
+	}
+	out := c.Msg()
+	out.Method, out.Params, out.Fields = "", nil, nil
+	switch {
+	case !c.Wrote():
+		out.Error = NewError(ErrInternal, "no response")
+	case w.status != http.StatusOK:
+		out.Result, out.Error = nil, NewError(ErrorCode(w.status), w.body.String())
+	}
+	return c.wireMessage(out)
+}
@@ -0,0 +1,193 @@
+package j2rpc
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	v1 "github.com/atcharles/glibs/j2rpc"
+	"github.com/atcharles/glibs/mdb"
+)
+
+// CacheKeyPrefix the prefix of the keys of the responses in ServerOption.CacheStore
+const CacheKeyPrefix = "j2rpc:"
+
+// CachePolicy marks a read-only method as cacheable, it can be called by
+// GET /rpc?method=user.get&params=<urlencoded json>&id=1&fields=name
+type CachePolicy struct {
+	//MaxAge the max-age of Cache-Control, and the TTL of the response in the CacheStore
+	MaxAge time.Duration
+	//Private the response depends on the caller, it's cached by the browser only and never stored
+	Private bool
+	//Vary the request headers the response depends on, they are sent as Vary and are part of the cache key
+	Vary []string
+}
+
+// ImplRPCCachePolicy declares the cacheable methods of a type, nil if the method isn't cacheable
+type ImplRPCCachePolicy interface {
+	RPCCachePolicy(methodName string) *CachePolicy
+}
+
+// cacheControl ...
+func (p *CachePolicy) cacheControl() string {
+	scope := "public"
+	if p.Private {
+		scope = "private"
+	}
+	return scope + ", max-age=" + strconv.FormatInt(int64(p.MaxAge/time.Second), 10)
+}
+
+// cachePolicy the policy of the method, nil if it isn't cacheable
+func (o *ServerOption) cachePolicy(method string) *CachePolicy {
+	return o.CachePolicies[method]
+}
+
+// InvalidateCache drops the stored responses of the methods, or of all methods of the namespaces
+func (s *server) InvalidateCache(methods ...string) {
+	store := s.option.CacheStore
+	if store == nil {
+		return
+	}
+	prefixes := make([]string, 0, len(methods)*2)
+	for _, method := range methods {
+		prefixes = append(prefixes, CacheKeyPrefix+method+":", CacheKeyPrefix+method+Separator)
+	}
+	store.DropPrefix(prefixes...)
+}
+
+// handleCache serves the stored response of a cacheable GET call, it runs after the handlers of the groups
+func (s *server) handleCache() Handler {
+	return func(c Context) {
+		defer c.Next()
+		ctx, ok := c.(*rpcContext)
+		if !ok || c.Wrote() || s.option.CacheStore == nil {
+			return
+		}
+		policy := ctx.cachePolicy()
+		if policy == nil || policy.Private {
+			return
+		}
+		data, ok := s.option.CacheStore.Get(ctx.cacheKey(policy))
+		if !ok {
+			return
+		}
+		c.Abort()
+		ctx.writeData(data)
+	}
+}
+
+// Invalidate a handler which drops the stored responses of the methods after a successful call, e.g.
+//
+//	srv.Use("user.update", j2rpc.Invalidate(srv, "user.get", "user.list"))
+func Invalidate(srv Server, methods ...string) Handler {
+	return func(c Context) {
+		c.Next()
+		if c.Wrote() && c.Msg().Error == nil {
+			srv.InvalidateCache(methods...)
+		}
+	}
+}
+
+// WithCachePolicy marks the methods as cacheable
+func WithCachePolicy(policy CachePolicy, methods ...string) Option {
+	return func(option *ServerOption) {
+		if option.CachePolicies == nil {
+			option.CachePolicies = make(map[string]*CachePolicy)
+		}
+		for _, method := range methods {
+			p := policy
+			option.CachePolicies[method] = &p
+		}
+	}
+}
+
+// WithCacheStore stores the responses of the cacheable methods
+func WithCacheStore(store mdb.CacheStore) Option {
+	return func(option *ServerOption) {
+		option.CacheStore = store
+	}
+}
+
+// cacheKey the key of the response: the method, and a hash of the query and the Vary headers
+func (r *rpcContext) cacheKey(policy *CachePolicy) string {
+	query := r.req.URL.Query()
+	h := sha256.New()
+	for _, key := range []string{"id", "params", "fields"} {
+		h.Write([]byte(query.Get(key)))
+		h.Write([]byte{0})
+	}
+	h.Write([]byte(mediaType(r.responseCodec().ContentType())))
+	h.Write([]byte{0})
+	for _, header := range policy.Vary {
+		h.Write([]byte(r.req.Header.Get(header)))
+		h.Write([]byte{0})
+	}
+	return CacheKeyPrefix + r.Msg().Method + ":" + hex.EncodeToString(h.Sum(nil))
+}
+
+// cachePolicy the policy of a successful GET call, nil if the response isn't cacheable
+func (r *rpcContext) cachePolicy() *CachePolicy {
+	if r.req == nil || r.req.Method != http.MethodGet || r.Server() == nil {
+		return nil
+	}
+	msg := r.Msg()
+	if msg.Error != nil {
+		return nil
+	}
+	return r.Server().Option().cachePolicy(msg.Method)
+}
+
+// readQuery reads the message of a GET call, only the cacheable methods can be called by GET
+func (r *rpcContext) readQuery() (*RPCMessage, int, error) {
+	query := r.req.URL.Query()
+	msg := &RPCMessage{
+		ID:      RawMessage(strings.TrimSpace(query.Get("id"))),
+		Version: "2.0",
+		Method:  strings.TrimSpace(query.Get("method")),
+		Params:  RawMessage(query.Get("params")),
+		Fields:  v1.ParseFields(query.Get("fields")),
+	}
+	if len(msg.ID) == 0 {
+		msg.ID = RawMessage{'0'}
+	}
+	if msg.Method == "" {
+		return nil, http.StatusBadRequest, errors.New("missing method")
+	}
+	if !msg.hasValidID() {
+		return nil, http.StatusBadRequest, errors.New("invalid request id")
+	}
+	if r.Server().Option().cachePolicy(msg.Method) == nil {
+		return nil, http.StatusMethodNotAllowed, errors.New("method isn't cacheable")
+	}
+	return msg, 0, nil
+}
+
+// storeCache ...
+func (r *rpcContext) storeCache(policy *CachePolicy, data []byte) {
+	store := r.Server().Option().CacheStore
+	if store == nil || policy.Private || policy.MaxAge < time.Second {
+		return
+	}
+	store.Set(r.cacheKey(policy), data, int64(policy.MaxAge/time.Second))
+}
+
+// writeCacheHeaders sets the cache headers of a cacheable response, true if the client has it (If-None-Match)
+func (r *rpcContext) writeCacheHeaders(policy *CachePolicy, data []byte) bool {
+	sum := sha256.Sum256(data)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	header := r.Writer().Header()
+	header.Set("ETag", etag)
+	header.Set("Cache-Control", policy.cacheControl())
+	header.Set("Vary", strings.Join(append([]string{"Accept"}, policy.Vary...), ", "))
+	for _, match := range strings.Split(r.req.Header.Get("If-None-Match"), ",") {
+		match = strings.TrimPrefix(strings.TrimSpace(match), "W/")
+		if match == etag || match == "*" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,112 @@
+package j2rpc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/atcharles/glibs/mdb"
+)
+
+type cacheService struct {
+	calls atomic.Int64
+}
+
+func (s *cacheService) Get(_ context.Context, id int) (map[string]int, error) {
+	s.calls.Add(1)
+	return map[string]int{"id": id}, nil
+}
+
+func (s *cacheService) Mine(_ context.Context, id int) (int, error) {
+	s.calls.Add(1)
+	return id, nil
+}
+
+func (s *cacheService) Set(_ context.Context, id int) (int, error) { return id, nil }
+
+func TestCacheGET(t *testing.T) {
+	svc := new(cacheService)
+	s := NewServer(
+		WithCachePolicy(CachePolicy{MaxAge: time.Minute}, "item.get"),
+		WithCachePolicy(CachePolicy{MaxAge: time.Minute, Private: true}, "item.mine"),
+		WithCacheStore(mdb.NewFreeCacheStore()),
+	)
+	s.RegisterType(svc, "item")
+	get := func(method, params string, header http.Header) *httptest.ResponseRecorder {
+		query := url.Values{"method": {method}, "params": {params}}
+		req := httptest.NewRequest(http.MethodGet, "/?"+query.Encode(), nil)
+		for key, values := range header {
+			req.Header[key] = values
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		return w
+	}
+
+	first := get("item.get", "[1]", nil)
+	require.Equal(t, http.StatusOK, first.Code, first.Body.String())
+	etag := first.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+	assert.Equal(t, "public, max-age=60", first.Header().Get("Cache-Control"))
+	assert.Equal(t, "Accept", first.Header().Get("Vary"))
+	assert.JSONEq(t, `{"id":0,"jsonrpc":"2.0","result":{"id":1}}`, first.Body.String())
+
+	tests := []struct {
+		name   string
+		method string
+		params string
+		header http.Header
+		status int
+		etag   string
+		// calls the number of calls of the methods after the request
+		calls int64
+	}{
+		{"stored", "item.get", "[1]", nil, http.StatusOK, etag, 1},
+		{"not modified", "item.get", "[1]", http.Header{"If-None-Match": {etag}}, http.StatusNotModified, etag, 1},
+		{"weak not modified", "item.get", "[1]", http.Header{"If-None-Match": {`"x", W/` + etag}}, http.StatusNotModified, etag, 1},
+		{"modified", "item.get", "[1]", http.Header{"If-None-Match": {`"x"`}}, http.StatusOK, etag, 1},
+		{"other params", "item.get", "[2]", nil, http.StatusOK, "", 2},
+		{"private isn't stored", "item.mine", "[1]", nil, http.StatusOK, "", 3},
+		{"private again", "item.mine", "[1]", nil, http.StatusOK, "", 4},
+		{"not cacheable", "item.set", "[1]", nil, http.StatusMethodNotAllowed, "", 4},
+		{"missing method", "", "[1]", nil, http.StatusBadRequest, "", 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := get(tt.method, tt.params, tt.header)
+			require.Equal(t, tt.status, w.Code, w.Body.String())
+			assert.Equal(t, tt.calls, svc.calls.Load())
+			if tt.etag != "" {
+				assert.Equal(t, tt.etag, w.Header().Get("ETag"))
+			}
+			switch tt.status {
+			case http.StatusNotModified:
+				assert.Empty(t, w.Body.String())
+			case http.StatusOK:
+				assert.NotEmpty(t, w.Header().Get("ETag"))
+			}
+			if tt.method == "item.mine" {
+				assert.Equal(t, "private, max-age=60", w.Header().Get("Cache-Control"))
+			}
+		})
+	}
+
+	// a POST call isn't cached
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"item.get","params":[1]}`)))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("ETag"))
+	assert.Equal(t, int64(5), svc.calls.Load())
+
+	s.InvalidateCache("item.get")
+	require.Equal(t, http.StatusOK, get("item.get", "[1]", nil).Code)
+	assert.Equal(t, int64(6), svc.calls.Load())
+}
@@ -0,0 +1,364 @@
+package j2rpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Codec encodes the messages on the wire, it's selected by Content-Type for the request and by Accept
+// for the response. The envelope and the params are decoded by the request codec, the params of Msg()
+// are left encoded by it, and the result of the method is encoded by the response codec.
+// The id is kept as JSON, and a result written as JSON by a handler is converted to the response codec.
+// The codecs other than JSON, MessagePack and CBOR split the arrays and maps by decoding and encoding
+// their elements again.
+type Codec interface {
+	//ContentType the media type, e.g. application/msgpack
+	ContentType() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+var (
+	// JSONCodec the default codec
+	JSONCodec Codec = jsonCodec{}
+	// MsgpackCodec application/msgpack
+	MsgpackCodec Codec = msgpackCodec{}
+	// CBORCodec application/cbor
+	CBORCodec Codec = newCBORCodec()
+)
+
+type (
+	jsonCodec    struct{}
+	msgpackCodec struct{}
+	cborCodec    struct {
+		enc cbor.EncMode
+		dec cbor.DecMode
+	}
+)
+
+func (jsonCodec) ContentType() string { return "application/json; charset=utf-8" }
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) { return JSONEncode(v) }
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return JSONDecode(data, v) }
+
+func (msgpackCodec) ContentType() string { return "application/msgpack" }
+
+func (msgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	enc := msgpack.NewEncoder(buf)
+	enc.SetCustomStructTag("json")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (msgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	return dec.Decode(v)
+}
+
+func (c cborCodec) ContentType() string { return "application/cbor" }
+
+func (c cborCodec) Marshal(v interface{}) ([]byte, error) { return c.enc.Marshal(v) }
+
+func (c cborCodec) Unmarshal(data []byte, v interface{}) error { return c.dec.Unmarshal(data, v) }
+
+// newCBORCodec decodes the maps of interface{} with string keys, as the ones of JSON
+func newCBORCodec() cborCodec {
+	enc, _ := cbor.EncOptions{Time: cbor.TimeRFC3339Nano}.EncMode()
+	dec, _ := cbor.DecOptions{DefaultMapType: reflect.TypeOf(map[string]interface{}(nil))}.DecMode()
+	return cborCodec{enc: enc, dec: dec}
+}
+
+// WithCodec adds the codecs, or replaces the ones of the same content type
+func WithCodec(codecs ...Codec) Option {
+	return func(option *ServerOption) {
+		for _, codec := range codecs {
+			replaced := false
+			for i, c := range option.Codecs {
+				if mediaType(c.ContentType()) == mediaType(codec.ContentType()) {
+					option.Codecs[i], replaced = codec, true
+				}
+			}
+			if !replaced {
+				option.Codecs = append(option.Codecs, codec)
+			}
+		}
+	}
+}
+
+// codec the codec of the media type, nil if none
+func (o *ServerOption) codec(contentType string) Codec {
+	typ := mediaType(contentType)
+	if len(typ) == 0 {
+		return nil
+	}
+	for _, codec := range o.Codecs {
+		if mediaType(codec.ContentType()) == typ {
+			return codec
+		}
+	}
+	return nil
+}
+
+// requestCodec the codec of Content-Type, JSON if it's unknown, the params of GET are JSON
+func (r *rpcContext) requestCodec() Codec {
+	if r.req == nil || r.req.Method == http.MethodGet || r.Server() == nil {
+		return JSONCodec
+	}
+	if codec := r.Server().Option().codec(r.req.Header.Get("Content-Type")); codec != nil {
+		return codec
+	}
+	return JSONCodec
+}
+
+// responseCodec the first codec of Accept, or the codec of the request
+func (r *rpcContext) responseCodec() Codec {
+	if r.req == nil || r.Server() == nil {
+		return JSONCodec
+	}
+	for _, accept := range strings.Split(r.req.Header.Get("Accept"), ",") {
+		if codec := r.Server().Option().codec(accept); codec != nil {
+			return codec
+		}
+	}
+	return r.requestCodec()
+}
+
+// requestCodecOf the request codec of c, JSON if c isn't the server's Context
+func requestCodecOf(c Context) Codec {
+	if r, ok := c.(*rpcContext); ok {
+		return r.requestCodec()
+	}
+	return JSONCodec
+}
+
+// rawCodec splits the encoded arrays and maps without decoding their elements
+type rawCodec interface {
+	//kind of the encoded value: '[' array, '{' map, 'n' null or nothing, 0 others
+	kind(data []byte) byte
+	splitArray(data []byte) ([][]byte, error)
+	splitMap(data []byte) (map[string][]byte, error)
+}
+
+func (jsonCodec) kind(data []byte) byte {
+	data = bytes.TrimSpace(data)
+	switch {
+	case len(data) == 0 || bytes.Equal(data, []byte("null")):
+		return 'n'
+	case data[0] == '[' || data[0] == '{':
+		return data[0]
+	}
+	return 0
+}
+
+func (jsonCodec) splitArray(data []byte) ([][]byte, error) {
+	var elements []RawMessage
+	if err := JSONDecode(data, &elements); err != nil {
+		return nil, err
+	}
+	return rawSlice(elements), nil
+}
+
+func (jsonCodec) splitMap(data []byte) (map[string][]byte, error) {
+	var fields map[string]RawMessage
+	if err := JSONDecode(data, &fields); err != nil {
+		return nil, err
+	}
+	return rawMap(fields), nil
+}
+
+func (msgpackCodec) kind(data []byte) byte {
+	if len(data) == 0 {
+		return 'n'
+	}
+	switch b := data[0]; {
+	case b == 0xc0:
+		return 'n'
+	case b >= 0x90 && b <= 0x9f, b == 0xdc, b == 0xdd:
+		return '['
+	case b >= 0x80 && b <= 0x8f, b == 0xde, b == 0xdf:
+		return '{'
+	}
+	return 0
+}
+
+func (c msgpackCodec) splitArray(data []byte) ([][]byte, error) {
+	var elements []msgpack.RawMessage
+	if err := c.Unmarshal(data, &elements); err != nil {
+		return nil, err
+	}
+	return rawSlice(elements), nil
+}
+
+func (c msgpackCodec) splitMap(data []byte) (map[string][]byte, error) {
+	var fields map[string]msgpack.RawMessage
+	if err := c.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	return rawMap(fields), nil
+}
+
+func (c cborCodec) kind(data []byte) byte {
+	if len(data) == 0 {
+		return 'n'
+	}
+	switch b := data[0]; {
+	case b == 0xf6 || b == 0xf7:
+		return 'n'
+	case b>>5 == 4:
+		return '['
+	case b>>5 == 5:
+		return '{'
+	}
+	return 0
+}
+
+func (c cborCodec) splitArray(data []byte) ([][]byte, error) {
+	var elements []cbor.RawMessage
+	if err := c.Unmarshal(data, &elements); err != nil {
+		return nil, err
+	}
+	return rawSlice(elements), nil
+}
+
+func (c cborCodec) splitMap(data []byte) (map[string][]byte, error) {
+	var fields map[string]cbor.RawMessage
+	if err := c.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	return rawMap(fields), nil
+}
+
+// wireKind see rawCodec.kind
+func wireKind(codec Codec, data []byte) byte {
+	if rc, ok := codec.(rawCodec); ok {
+		return rc.kind(data)
+	}
+	if len(data) == 0 {
+		return 'n'
+	}
+	var val interface{}
+	if err := codec.Unmarshal(data, &val); err != nil {
+		return 0
+	}
+	switch val.(type) {
+	case nil:
+		return 'n'
+	case []interface{}:
+		return '['
+	case map[string]interface{}, map[interface{}]interface{}:
+		return '{'
+	}
+	return 0
+}
+
+// wireArray the encoded elements of the array
+func wireArray(codec Codec, data []byte) ([][]byte, error) {
+	if rc, ok := codec.(rawCodec); ok {
+		return rc.splitArray(data)
+	}
+	var values []interface{}
+	if err := codec.Unmarshal(data, &values); err != nil {
+		return nil, err
+	}
+	elements := make([][]byte, len(values))
+	for i, val := range values {
+		var err error
+		if elements[i], err = codec.Marshal(val); err != nil {
+			return nil, err
+		}
+	}
+	return elements, nil
+}
+
+// wireMap the encoded values of the map
+func wireMap(codec Codec, data []byte) (map[string][]byte, error) {
+	if rc, ok := codec.(rawCodec); ok {
+		return rc.splitMap(data)
+	}
+	var values map[string]interface{}
+	if err := codec.Unmarshal(data, &values); err != nil {
+		return nil, err
+	}
+	fields := make(map[string][]byte, len(values))
+	for key, val := range values {
+		bts, err := codec.Marshal(val)
+		if err != nil {
+			return nil, err
+		}
+		fields[key] = bts
+	}
+	return fields, nil
+}
+
+func rawSlice[T ~[]byte](elements []T) [][]byte {
+	out := make([][]byte, len(elements))
+	for i, element := range elements {
+		out[i] = element
+	}
+	return out
+}
+
+func rawMap[T ~[]byte](fields map[string]T) map[string][]byte {
+	out := make(map[string][]byte, len(fields))
+	for key, val := range fields {
+		out[key] = val
+	}
+	return out
+}
+
+// decodeJSONValue decodes the JSON of the id, or of a result written as JSON, for the response codec
+func decodeJSONValue(data []byte) interface{} {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var val interface{}
+	if err := dec.Decode(&val); err != nil {
+		return nil
+	}
+	return convertNumbers(val)
+}
+
+// convertNumbers converts the json.Number to int64, uint64 or float64, which are encoded as numbers by the codecs
+func convertNumbers(val interface{}) interface{} {
+	switch v := val.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		if n, err := strconv.ParseUint(v.String(), 10, 64); err == nil {
+			return n
+		}
+		n, _ := v.Float64()
+		return n
+	case map[string]interface{}:
+		for key, elem := range v {
+			v[key] = convertNumbers(elem)
+		}
+	case []interface{}:
+		for i, elem := range v {
+			v[i] = convertNumbers(elem)
+		}
+	}
+	return val
+}
+
+// mediaType the lowercase media type without parameters, application/x-msgpack is application/msgpack
+func mediaType(contentType string) string {
+	typ, _, err := mime.ParseMediaType(strings.TrimSpace(contentType))
+	if err != nil {
+		return ""
+	}
+	return strings.Replace(typ, "/x-", "/", 1)
+}
@@ -0,0 +1,134 @@
+package j2rpc
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type (
+	codecService struct{}
+
+	codecBlob struct {
+		Name string `json:"name"`
+		Data []byte `json:"data"`
+		Size int64  `json:"size"`
+	}
+)
+
+func (codecService) RPCTypeName() string { return "blob" }
+
+func (codecService) Echo(_ context.Context, b codecBlob) (codecBlob, error) {
+	b.Size = int64(len(b.Data))
+	return b, nil
+}
+
+func (codecService) Add(a, b int) (int, error) { return a + b, nil }
+
+func newCodecServer() Server {
+	s := NewServer()
+	s.RegisterType(codecService{})
+	Handle(s, "blob.handle", func(_ Context, req codecBlob) (*codecBlob, error) { return &req, nil })
+	return s
+}
+
+// codecCall posts the body encoded by codec and decodes the response by it
+func codecCall(t *testing.T, s Server, codec Codec, body interface{}, out interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	data, err := codec.Marshal(body)
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(data))
+	req.Header.Set("Content-Type", codec.ContentType())
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, codec.ContentType(), w.Header().Get("Content-Type"))
+	require.NoError(t, codec.Unmarshal(w.Body.Bytes(), out))
+	return w
+}
+
+type codecResponse[T any] struct {
+	ID      int64  `json:"id"`
+	Version string `json:"jsonrpc"`
+	Result  T      `json:"result"`
+	Error   *Error `json:"error"`
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	s := newCodecServer()
+	blob := codecBlob{Name: "a", Data: []byte{0, 1, 2, 0xff}}
+	for _, codec := range []Codec{JSONCodec, MsgpackCodec, CBORCodec} {
+		t.Run(mediaType(codec.ContentType()), func(t *testing.T) {
+			for _, method := range []string{"blob.echo", "blob.handle"} {
+				var resp codecResponse[codecBlob]
+				codecCall(t, s, codec, map[string]interface{}{
+					"jsonrpc": "2.0", "id": 7, "method": method, "params": []interface{}{blob},
+				}, &resp)
+				require.Nil(t, resp.Error)
+				assert.Equal(t, int64(7), resp.ID)
+				assert.Equal(t, blob.Data, resp.Result.Data, method)
+				assert.Equal(t, blob.Name, resp.Result.Name, method)
+			}
+
+			var add codecResponse[int]
+			codecCall(t, s, codec, map[string]interface{}{
+				"jsonrpc": "2.0", "id": 1, "method": "blob.add", "params": []int{2, 3},
+			}, &add)
+			require.Nil(t, add.Error)
+			assert.Equal(t, 5, add.Result)
+
+			var null codecResponse[int]
+			codecCall(t, s, codec, map[string]interface{}{
+				"jsonrpc": "2.0", "id": 2, "method": "blob.add", "params": []interface{}{1, nil},
+			}, &null)
+			require.Nil(t, null.Error)
+			assert.Equal(t, 1, null.Result)
+
+			var bad codecResponse[int]
+			codecCall(t, s, codec, map[string]interface{}{
+				"jsonrpc": "2.0", "id": 3, "method": "blob.add", "params": []interface{}{1, "x"},
+			}, &bad)
+			require.NotNil(t, bad.Error)
+			assert.Equal(t, ErrBadParams, bad.Error.Code)
+
+			var batch []codecResponse[int]
+			codecCall(t, s, codec, []interface{}{
+				map[string]interface{}{"jsonrpc": "2.0", "id": 1, "method": "blob.add", "params": []int{1, 1}},
+				map[string]interface{}{"jsonrpc": "2.0", "method": "blob.add", "params": []int{1, 2}},
+				map[string]interface{}{"jsonrpc": "2.0", "id": 3, "method": "blob.add", "params": []int{1, 3}},
+			}, &batch)
+			require.Len(t, batch, 2)
+			assert.Equal(t, []int64{1, 3}, []int64{batch[0].ID, batch[1].ID})
+			assert.Equal(t, []int{2, 4}, []int{batch[0].Result, batch[1].Result})
+		})
+	}
+}
+
+func TestCodecAccept(t *testing.T) {
+	s := newCodecServer()
+	data, err := JSONCodec.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": 1, "method": "blob.echo", "params": []interface{}{codecBlob{Data: []byte("hi")}}})
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/x-msgpack")
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, MsgpackCodec.ContentType(), w.Header().Get("Content-Type"))
+	var resp codecResponse[codecBlob]
+	require.NoError(t, MsgpackCodec.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, []byte("hi"), resp.Result.Data)
+	assert.Equal(t, int64(2), resp.Result.Size)
+
+	// the bytes are sent as binary, not as the base64 string of JSON
+	var raw struct {
+		Result map[string]interface{} `json:"result"`
+	}
+	require.NoError(t, MsgpackCodec.Unmarshal(w.Body.Bytes(), &raw))
+	assert.Equal(t, []byte("hi"), raw.Result["data"])
+}
@@ -3,11 +3,16 @@ package j2rpc
 import (
 	"bytes"
 	"context"
+	"errors"
+	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"strings"
 	"sync"
 	"sync/atomic"
+
+	v1 "github.com/atcharles/glibs/j2rpc"
 )
 
 const (
@@ -26,9 +31,25 @@ type rpcContext struct {
 	msg      *RPCMessage
 	abort    bool
 	wrote    bool
-	server   Server
+	//batch the elements of a batch request, the context has no message then
+	batch  []RawMessage
+	server Server
+	//result the value of the result, which is encoded by the response codec other than JSON
+	result    interface{}
+	hasResult bool
+}
+
+// codecMessage the response encoded by a codec other than JSON
+type codecMessage struct {
+	ID      interface{} `json:"id,omitempty"`
+	Version string      `json:"jsonrpc,omitempty"`
+	Result  interface{} `json:"result,omitempty"`
+	Error   *Error      `json:"error,omitempty"`
 }
 
+// resultValue the result of a method, it's encoded once by the response codec
+type resultValue struct{ val interface{} }
+
 // Abort ...
 func (r *rpcContext) Abort() {
 	r.Lock()
@@ -77,6 +98,7 @@ func (r *rpcContext) Msg() *RPCMessage {
 		Version: msg.Version,
 		Method:  msg.Method,
 		Params:  msg.Params,
+		Fields:  msg.Fields,
 		Result:  msg.Result,
 		Error:   msg.Error,
 	}
@@ -115,6 +137,15 @@ func (r *rpcContext) ReadBody() (err error) {
 		r.StopWriteStringStatus(status, err.Error())
 		return
 	}
+	if r.req.Method == http.MethodGet {
+		msg, status, err := r.readQuery()
+		if err != nil {
+			r.StopWriteStringStatus(status, err.Error())
+			return err
+		}
+		r.SetMsg(msg)
+		return nil
+	}
 	if r.req.Body == nil {
 		r.StopWriteStringStatus(http.StatusBadRequest, "missing request body")
 		return
@@ -127,25 +158,39 @@ func (r *rpcContext) ReadBody() (err error) {
 	}
 	//if we need reuse body, set body to req.Body
 	//r.req.Body = io.NopCloser(io.NewSectionReader(bytes.NewReader(body), 0, int64(len(body))))
+	codec := r.requestCodec()
+	if codec == JSONCodec {
+		body = bytes.TrimSpace(body)
+	}
 	if callerAfterReadBody := r.Server().Option().CallerAfterReadBody; callerAfterReadBody != nil {
-		if body, err = callerAfterReadBody(bytes.TrimSpace(body)); err != nil {
+		if body, err = callerAfterReadBody(body); err != nil {
 			r.StopWriteStringStatus(http.StatusBadRequest, err.Error())
 			return
 		}
 	}
 	r.SetValue(BodyContextKey, body)
-	msg := &RPCMessage{}
-	if err = JSONDecode(body, msg); err != nil {
-		r.StopWriteStringStatus(http.StatusBadRequest, err.Error())
-		return
-	}
-	if !msg.hasValidID() {
-		r.StopWriteStringStatus(http.StatusBadRequest, "invalid request id")
+	if wireKind(codec, body) == '[' {
+		var elements [][]byte
+		if elements, err = wireArray(codec, body); err != nil {
+			r.StopWriteStringStatus(http.StatusBadRequest, err.Error())
+			return
+		}
+		if len(elements) == 0 {
+			err = errors.New("empty batch")
+			r.StopWriteStringStatus(http.StatusBadRequest, err.Error())
+			return
+		}
+		r.Lock()
+		r.batch = make([]RawMessage, len(elements))
+		for i, element := range elements {
+			r.batch[i] = element
+		}
+		r.Unlock()
 		return
 	}
-	msg.Method = strings.TrimSpace(msg.Method)
-	if msg.Method == "" {
-		r.StopWriteStringStatus(http.StatusBadRequest, "missing method")
+	msg, err := decodeMessage(codec, body)
+	if err != nil {
+		r.StopWriteStringStatus(http.StatusBadRequest, err.Error())
 		return
 	}
 	r.SetMsg(msg)
@@ -163,6 +208,7 @@ func (r *rpcContext) SetMsg(msg *RPCMessage) {
 		Version: msg.Version,
 		Method:  msg.Method,
 		Params:  msg.Params,
+		Fields:  msg.Fields,
 		Result:  msg.Result,
 		Error:   msg.Error,
 	}
@@ -203,39 +249,111 @@ func (r *rpcContext) WriteResponse(args ...interface{}) {
 	msg := r.Msg()
 	for _, arg := range args {
 		switch _arg := arg.(type) {
-		case ItfJ2rpcError:
-			msg.Error = NewError(ErrorCode(_arg.ErrorCode()), _arg.Error(), _arg.ErrorData())
 		case error:
-			msg.Error = NewError(ErrInternal, _arg.Error())
+			msg.Error = r.mapError(_arg)
 		case []byte:
 			msg.Result = _arg
 		case RawMessage:
 			msg.Result = _arg
+		case resultValue:
+			msg.Error = r.setResult(msg, _arg.val)
 		}
 	}
-	if msg.Error == nil && msg.Result == nil {
+	if msg.Error == nil && msg.Result == nil && !r.hasResult {
 		ret, _ := JSONEncode("Success")
 		msg.Result = ret
 	}
 	if msg.Error != nil {
-		msg.Result = nil
+		msg.Result, r.result, r.hasResult = nil, nil, false
+	}
+	if msg.Error == nil && msg.Result != nil {
+		msg.Result = r.fields(msg).Project(msg.Result)
 	}
 	r.SetMsg(msg)
+	if msg.isNotification() {
+		if msg.Error != nil {
+			slog.Error("notification failed", slog.String("method", msg.Method), slog.String("error", msg.Error.Error()))
+		}
+		r.Writer().WriteHeader(http.StatusNoContent)
+		r.SetWrote(true)
+		return
+	}
 	msg.Method = ""
 	msg.Params = nil
-	data, err := JSONEncode(msg)
+	msg.Fields = nil
+	r.writeMessage(r.wireMessage(msg))
+}
+
+// setResult sets the result value of the method, it's encoded by the response codec when the response
+// is written, or as JSON now if the response is JSON or its fields are projected
+func (r *rpcContext) setResult(msg *RPCMessage, val interface{}) *Error {
+	if r.responseCodec() != JSONCodec && len(r.fields(msg)) == 0 {
+		r.result, r.hasResult = val, true
+		return nil
+	}
+	data, err := JSONEncode(val)
+	if err != nil {
+		return NewError(ErrInternal, err.Error())
+	}
+	msg.Result = bytes.TrimSpace(data)
+	return nil
+}
+
+// wireMessage the response to encode by the response codec, the message itself for JSON
+func (r *rpcContext) wireMessage(msg *RPCMessage) interface{} {
+	if r.responseCodec() == JSONCodec {
+		return msg
+	}
+	out := &codecMessage{Version: msg.Version, Error: msg.Error}
+	if len(msg.ID) > 0 {
+		out.ID = decodeJSONValue(msg.ID)
+	}
+	switch {
+	case msg.Error != nil:
+	case r.hasResult:
+		out.Result = r.result
+	case len(msg.Result) > 0:
+		out.Result = decodeJSONValue(msg.Result)
+	}
+	return out
+}
+
+// writeMessage encodes val by the response codec, passes it to CallerBeforeWrite and writes it
+func (r *rpcContext) writeMessage(val interface{}) {
+	codec := r.responseCodec()
+	data, err := codec.Marshal(val)
 	if err != nil {
 		r.StopWriteStringStatus(http.StatusInternalServerError, err.Error())
 		return
 	}
+	if codec == JSONCodec {
+		data = bytes.TrimSpace(data)
+	}
 	if callerBeforeWrite := r.Server().Option().CallerBeforeWrite; callerBeforeWrite != nil {
-		if data, err = callerBeforeWrite(bytes.TrimSpace(data)); err != nil {
+		if data, err = callerBeforeWrite(data); err != nil {
 			r.StopWriteStringStatus(http.StatusInternalServerError, err.Error())
 			return
 		}
 	}
-	r.Writer().Header().Set("Content-Type", "application/json; charset=utf-8")
+	if policy := r.cachePolicy(); policy != nil {
+		r.storeCache(policy, data)
+	}
+	r.writeData(data)
+}
+
+// writeData writes the encoded response, with the cache headers of a cacheable GET call
+func (r *rpcContext) writeData(data []byte) {
+	r.Writer().Header().Set("Content-Type", r.responseCodec().ContentType())
 	r.Writer().Header().Set("x-content-type-options", "nosniff")
+	if policy := r.cachePolicy(); policy != nil {
+		if r.writeCacheHeaders(policy, data) {
+			r.Writer().WriteHeader(http.StatusNotModified)
+			r.SetWrote(true)
+			return
+		}
+	} else if r.req != nil && r.req.Method == http.MethodGet {
+		r.Writer().Header().Set("Cache-Control", "no-store")
+	}
 	r.Writer().WriteHeader(http.StatusOK)
 	_, _ = r.Writer().Write(data)
 	r.SetWrote(true)
@@ -243,12 +361,89 @@ func (r *rpcContext) WriteResponse(args ...interface{}) {
 
 func (r *rpcContext) Writer() http.ResponseWriter { return r.writer }
 
+// batchElements ...
+func (r *rpcContext) batchElements() []RawMessage {
+	r.RLock()
+	defer r.RUnlock()
+	return r.batch
+}
+
+// fields the sparse fieldset of the message, or of the request header
+func (r *rpcContext) fields(msg *RPCMessage) v1.Fields {
+	if len(msg.Fields) > 0 || r.req == nil {
+		return msg.Fields
+	}
+	return v1.ParseFields(r.req.Header.Get(v1.FieldsHeader))
+}
+
+// mapError maps err with the server's error registry
+func (r *rpcContext) mapError(err error) *Error {
+	registry := v1.DefaultErrorRegistry
+	if s := r.Server(); s != nil && s.Option().ErrorRegistry != nil {
+		registry = s.Option().ErrorRegistry
+	}
+	e := registry.Map(err)
+	return NewError(ErrorCode(e.Code), e.Message, e.Data)
+}
+
 func (r *rpcContext) Wrote() bool {
 	r.RLock()
 	defer r.RUnlock()
 	return r.wrote
 }
 
+// decodeMessage decodes and checks a single request, the params are left encoded by the codec
+func decodeMessage(codec Codec, body []byte) (*RPCMessage, error) {
+	msg := &RPCMessage{}
+	if codec == JSONCodec {
+		if err := JSONDecode(body, msg); err != nil {
+			return nil, err
+		}
+	} else if err := decodeCodecMessage(codec, body, msg); err != nil {
+		return nil, err
+	}
+	msg.Method = strings.TrimSpace(msg.Method)
+	if msg.Method == "" {
+		return nil, errors.New("missing method")
+	}
+	if !msg.isNotification() && !msg.hasValidID() {
+		return nil, errors.New("invalid request id")
+	}
+	return msg, nil
+}
+
+// decodeCodecMessage decodes the envelope of a codec other than JSON, the id is converted to JSON
+func decodeCodecMessage(codec Codec, body []byte, msg *RPCMessage) error {
+	if wireKind(codec, body) != '{' {
+		return errors.New("invalid request")
+	}
+	fields, err := wireMap(codec, body)
+	if err != nil {
+		return err
+	}
+	for key, val := range map[string]interface{}{"jsonrpc": &msg.Version, "method": &msg.Method, "fields": &msg.Fields} {
+		if raw, ok := fields[key]; ok && wireKind(codec, raw) != 'n' {
+			if err = codec.Unmarshal(raw, val); err != nil {
+				return fmt.Errorf("invalid %s: %s", key, err.Error())
+			}
+		}
+	}
+	if raw, ok := fields["params"]; ok && wireKind(codec, raw) != 'n' {
+		msg.Params = raw
+	}
+	if raw, ok := fields["id"]; ok && wireKind(codec, raw) != 'n' {
+		var id interface{}
+		if err = codec.Unmarshal(raw, &id); err != nil {
+			return fmt.Errorf("invalid request id: %s", err.Error())
+		}
+		if msg.ID, err = JSONEncode(id); err != nil {
+			return fmt.Errorf("invalid request id: %s", err.Error())
+		}
+		msg.ID = bytes.TrimSpace(msg.ID)
+	}
+	return nil
+}
+
 func NewContext(ctx context.Context, writer http.ResponseWriter, req *http.Request) Context {
 	return newRpcContext(ctx, writer, req)
 }
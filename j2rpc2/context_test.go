@@ -0,0 +1,66 @@
+package j2rpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	v1 "github.com/atcharles/glibs/j2rpc"
+)
+
+var errRecordNotFound = errors.New("record not found")
+
+type errorService struct{}
+
+func (errorService) Find(_ context.Context, kind string) (string, error) {
+	switch kind {
+	case "missing":
+		return "", fmt.Errorf("find: %w", errRecordNotFound)
+	case "forbidden":
+		return "", v1.NewError(v1.ErrForbidden, "denied")
+	}
+	return "", errors.New("db down")
+}
+
+func TestErrorRegistry(t *testing.T) {
+	tests := []struct {
+		name     string
+		registry *v1.ErrorRegistry
+		kind     string
+		code     ErrorCode
+		msg      string
+	}{
+		{"default", nil, "missing", ErrInternal, "find: record not found"},
+		{"registered", v1.NewErrorRegistry().RegisterIs(errRecordNotFound, 404, "not found"), "missing", 404, "not found"},
+		{"rpc error", v1.NewErrorRegistry(), "forbidden", ErrorCode(v1.ErrForbidden), "denied"},
+		{"production", v1.NewErrorRegistry().SetProduction(true).SetLogger(v1.NewLevelLogger("[TEST]")), "other", ErrInternal, "internal error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []Option
+			if tt.registry != nil {
+				opts = append(opts, WithErrorRegistry(tt.registry))
+			}
+			s := NewServer(opts...)
+			s.RegisterType(errorService{}, "e")
+			body := `{"jsonrpc":"2.0","id":1,"method":"e.find","params":["` + tt.kind + `"]}`
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+			var resp struct {
+				Error *Error `json:"error"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp), w.Body.String())
+			require.NotNil(t, resp.Error)
+			assert.Equal(t, tt.code, resp.Error.Code)
+			assert.Equal(t, tt.msg, resp.Error.Message)
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package j2rpc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	v1 "github.com/atcharles/glibs/j2rpc"
+)
+
+type fieldsService struct{}
+
+func (s *fieldsService) Get(_ context.Context) (map[string]interface{}, error) {
+	return map[string]interface{}{"id": 1, "name": "alice", "tags": []map[string]int{{"id": 1, "n": 2}}}, nil
+}
+
+func TestFields(t *testing.T) {
+	s := NewServer()
+	s.RegisterType(new(fieldsService), "user")
+	tests := []struct {
+		name   string
+		body   string
+		header string
+		want   string
+	}{
+		{"all", `{"jsonrpc":"2.0","id":1,"method":"user.get","params":[]}`, "", `{"id":1,"name":"alice","tags":[{"id":1,"n":2}]}`},
+		{"member", `{"jsonrpc":"2.0","id":1,"method":"user.get","params":[],"fields":["name","tags.id"]}`, "", `{"name":"alice","tags":[{"id":1}]}`},
+		{"header", `{"jsonrpc":"2.0","id":1,"method":"user.get","params":[]}`, "id", `{"id":1}`},
+		{"member over header", `{"jsonrpc":"2.0","id":1,"method":"user.get","params":[],"fields":"name"}`, "id", `{"name":"alice"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			if len(tt.header) > 0 {
+				r.Header.Set(v1.FieldsHeader, tt.header)
+			}
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, r)
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+			assert.JSONEq(t, `{"jsonrpc":"2.0","id":1,"result":`+tt.want+`}`, w.Body.String())
+		})
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"io"
 	"net/http"
 	"reflect"
+
+	"github.com/atcharles/glibs/util"
 )
 
 const maxRequestContentLength = 1 << 20 * 5
@@ -24,7 +26,7 @@ func argsToNameInterface(args ...interface{}) (name string, bean interface{}) {
 }
 
 func getValidateRequestStatus(r *http.Request) (int, error) {
-	if r.Method == http.MethodOptions {
+	if r.Method == http.MethodOptions || r.Method == http.MethodGet {
 		return 0, nil
 	}
 	if r.Method != http.MethodPost {
@@ -56,6 +58,61 @@ func parseArgumentArray(dec *Decoder, types []reflect.Type) ([]reflect.Value, er
 	return args, err
 }
 
+// parseArguments parses the positional args, or an object mapped to the args by names, encoded by the codec.
+// When names is empty, the object is decoded into the only struct argument.
+func parseArguments(codec Codec, rawArgs RawMessage, types []reflect.Type, names []string) ([]reflect.Value, error) {
+	if codec != JSONCodec {
+		return parseCodecArguments(codec, rawArgs, types, names)
+	}
+	trimmed := bytes.TrimLeft(rawArgs, " \t\r\n")
+	if len(trimmed) == 0 || trimmed[0] != '{' {
+		return parsePositionalArguments(rawArgs, types)
+	}
+	args, err := parseArgumentObject(trimmed, types, names)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(args); i < len(types); i++ {
+		args = append(args, ZeroValue(types[i]))
+	}
+	return args, nil
+}
+
+func parseArgumentObject(rawArgs RawMessage, types []reflect.Type, names []string) ([]reflect.Value, error) {
+	if len(names) == 0 {
+		if len(types) != 1 || IndirectType(types[0]).Kind() != reflect.Struct {
+			return nil, errors.New("non-array args")
+		}
+		agv := reflect.New(types[0])
+		if err := JSONDecode(rawArgs, agv.Interface()); err != nil {
+			return nil, fmt.Errorf("invalid argument 0: %s", err.Error())
+		}
+		return []reflect.Value{agv.Elem()}, nil
+	}
+	var fields map[string]RawMessage
+	if err := JSONDecode(rawArgs, &fields); err != nil {
+		return nil, err
+	}
+	args := make([]reflect.Value, 0, len(types))
+	for i := 0; i < len(types) && i < len(names); i++ {
+		raw, ok := fields[names[i]]
+		if !ok {
+			args = append(args, ZeroValue(types[i]))
+			continue
+		}
+		agv := reflect.New(types[i])
+		if err := JSONDecode(raw, agv.Interface()); err != nil {
+			return args, fmt.Errorf("invalid argument %s: %s", names[i], err.Error())
+		}
+		if agv.Elem().Kind() == reflect.Ptr && agv.Elem().IsNil() {
+			args = append(args, ZeroValue(types[i]))
+			continue
+		}
+		args = append(args, agv.Elem())
+	}
+	return args, nil
+}
+
 func parsePositionalArguments(rawArgs RawMessage, types []reflect.Type) ([]reflect.Value, error) {
 	dec := NewDecoder(bytes.NewReader(rawArgs))
 	var args []reflect.Value
@@ -76,3 +133,93 @@ func parsePositionalArguments(rawArgs RawMessage, types []reflect.Type) ([]refle
 	}
 	return args, nil
 }
+
+// parseCodecArguments parseArguments of a codec other than JSON
+func parseCodecArguments(codec Codec, rawArgs []byte, types []reflect.Type, names []string) ([]reflect.Value, error) {
+	args := make([]reflect.Value, 0, len(types))
+	switch wireKind(codec, rawArgs) {
+	case 'n':
+	case '[':
+		elements, err := wireArray(codec, rawArgs)
+		if err != nil {
+			return nil, err
+		}
+		if len(elements) > len(types) {
+			return nil, fmt.Errorf("too many arguments, want at most %d", len(types))
+		}
+		for i, element := range elements {
+			// null is the zero value, as in JSON
+			if wireKind(codec, element) == 'n' {
+				args = append(args, ZeroValue(types[i]))
+				continue
+			}
+			agv := reflect.New(types[i])
+			if err = codec.Unmarshal(element, agv.Interface()); err != nil {
+				return nil, fmt.Errorf("invalid argument %d: %s", i, err.Error())
+			}
+			args = append(args, agv.Elem())
+		}
+	case '{':
+		if len(names) == 0 {
+			if len(types) != 1 || IndirectType(types[0]).Kind() != reflect.Struct {
+				return nil, errors.New("non-array args")
+			}
+			agv := reflect.New(types[0])
+			if err := codec.Unmarshal(rawArgs, agv.Interface()); err != nil {
+				return nil, fmt.Errorf("invalid argument 0: %s", err.Error())
+			}
+			return []reflect.Value{agv.Elem()}, nil
+		}
+		fields, err := wireMap(codec, rawArgs)
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < len(types) && i < len(names); i++ {
+			raw, ok := fields[names[i]]
+			if !ok || wireKind(codec, raw) == 'n' {
+				args = append(args, ZeroValue(types[i]))
+				continue
+			}
+			agv := reflect.New(types[i])
+			if err = codec.Unmarshal(raw, agv.Interface()); err != nil {
+				return nil, fmt.Errorf("invalid argument %s: %s", names[i], err.Error())
+			}
+			args = append(args, agv.Elem())
+		}
+	default:
+		return nil, errors.New("non-array args")
+	}
+	for i := len(args); i < len(types); i++ {
+		args = append(args, ZeroValue(types[i]))
+	}
+	return args, nil
+}
+
+// validateArguments validates the struct arguments with validate tags,
+// a failure is ErrBadParams with the error of each field as data.
+func validateArguments(v *util.Validator, args []reflect.Value) *Error {
+	for _, arg := range args {
+		if IndirectType(arg.Type()).Kind() != reflect.Struct || !util.HasValidateTag(arg.Type()) {
+			continue
+		}
+		if arg.Kind() == reflect.Ptr && arg.IsNil() {
+			continue
+		}
+		if e := validateValue(v, arg.Interface()); e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+// validateValue validates a struct with validate tags
+func validateValue(v *util.Validator, val interface{}) *Error {
+	fields, err := v.ValidFieldsZh(val)
+	if err == nil {
+		return nil
+	}
+	if len(fields) == 0 {
+		return NewError(ErrBadParams, err.Error())
+	}
+	return NewError(ErrBadParams, err.Error(), fields)
+}
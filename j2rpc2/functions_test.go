@@ -0,0 +1,81 @@
+package j2rpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type (
+	paramsService struct{}
+
+	paramsPoint struct {
+		X int `json:"x"`
+		Y int `json:"y"`
+	}
+)
+
+func (paramsService) RPCParamNames(methodName string) []string {
+	if methodName == "Move" {
+		return []string{"x", "to"}
+	}
+	return nil
+}
+
+func (paramsService) Move(_ context.Context, x int, to string) (string, error) {
+	return to + ":" + strconv.Itoa(x), nil
+}
+
+func (paramsService) Point(_ context.Context, p paramsPoint) (paramsPoint, error) { return p, nil }
+
+func (paramsService) Pair(a, b int) (int, error) { return a + b, nil }
+
+func TestNamedParams(t *testing.T) {
+	s := NewServer()
+	s.RegisterType(paramsService{}, "p")
+	tests := []struct {
+		name   string
+		method string
+		params string
+		// want the result, or the error code when it's not 0
+		want string
+		code ErrorCode
+	}{
+		{"positional", "p.move", `[1,"a"]`, `"a:1"`, 0},
+		{"named", "p.move", `{"x":1,"to":"a"}`, `"a:1"`, 0},
+		{"named in any order", "p.move", `{"to":"b","x":2}`, `"b:2"`, 0},
+		{"named missing", "p.move", `{"x":3}`, `":3"`, 0},
+		{"named wrong type", "p.move", `{"x":"a","to":"b"}`, "", ErrBadParams},
+		{"struct object", "p.point", `{"x":1,"y":2}`, `{"x":1,"y":2}`, 0},
+		{"struct array", "p.point", `[{"x":3}]`, `{"x":3,"y":0}`, 0},
+		{"object without names", "p.pair", `{"a":1,"b":2}`, "", ErrBadParams},
+		{"array without names", "p.pair", `[1,2]`, "3", 0},
+		{"too many", "p.pair", `[1,2,3]`, "", ErrBadParams},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `{"jsonrpc":"2.0","id":1,"method":"` + tt.method + `","params":` + tt.params + `}`
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+			var resp struct {
+				Result json.RawMessage `json:"result"`
+				Error  *Error          `json:"error"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp), w.Body.String())
+			if tt.code != 0 {
+				require.NotNil(t, resp.Error, w.Body.String())
+				assert.Equal(t, tt.code, resp.Error.Code)
+				return
+			}
+			require.Nil(t, resp.Error, w.Body.String())
+			assert.JSONEq(t, tt.want, string(resp.Result))
+		})
+	}
+}
@@ -15,3 +15,46 @@ func (g *rpcGroup) Use(handlers ...Handler) Group {
 func NewGroup(path string) Group {
 	return &rpcGroup{path: path, handlers: make([]Handler, 0)}
 }
+
+// groupWildcard matches any one segment of the method, e.g. admin.*.delete
+const groupWildcard = "*"
+
+// groupMatch reports whether the segments of the group path match the leading segments of the method
+func groupMatch(path, method []string) bool {
+	if len(path) > len(method) {
+		return false
+	}
+	for i, seg := range path {
+		if seg != groupWildcard && seg != method[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// groupLess orders the group paths from the most general to the most specific:
+// fewer segments first, then fewer literal (not wildcard) segments, then by name
+func groupLess(a, b []string) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	if la, lb := literalSegments(a), literalSegments(b); la != lb {
+		return la < lb
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return false
+}
+
+// literalSegments ...
+func literalSegments(path []string) (n int) {
+	for _, seg := range path {
+		if seg != groupWildcard {
+			n++
+		}
+	}
+	return
+}
@@ -30,10 +30,13 @@ type Group interface {
 
 type Server interface {
 	Handler(c Context)
+	InvalidateCache(methods ...string)
 	Option() *ServerOption
 	RegisterFunc(args ...interface{})
+	RegisterRoute(route RouteInfo, handlers ...Handler)
 	RegisterType(args ...interface{})
 	RegisterTypeBus(bus interface{})
+	Routes() []RouteInfo
 	ServeHTTP(writer http.ResponseWriter, request *http.Request)
 	Use(path string, handlers ...Handler) Group
 }
@@ -1,5 +1,9 @@
 package j2rpc
 
+import (
+	v1 "github.com/atcharles/glibs/j2rpc"
+)
+
 const (
 	ErrParse          ErrorCode = -32700
 	ErrInvalidRequest ErrorCode = -32600
@@ -39,12 +43,16 @@ type RPCMessage struct {
 	Version string     `json:"jsonrpc,omitempty"`
 	Method  string     `json:"method,omitempty"`
 	Params  RawMessage `json:"params,omitempty"`
+	Fields  v1.Fields  `json:"fields,omitempty"`
 	Result  RawMessage `json:"result,omitempty"`
 	Error   *Error     `json:"error,omitempty"`
 }
 
 func (r *RPCMessage) hasValidID() bool { return len(r.ID) > 0 && r.ID[0] != '{' && r.ID[0] != '[' }
 
+// isNotification reports whether the message is a call without id, which expects no response
+func (r *RPCMessage) isNotification() bool { return len(r.ID) == 0 && len(r.Method) > 0 }
+
 // NewError ...
 func NewError(code ErrorCode, Msg string, data ...interface{}) *Error {
 	ee := &Error{Code: code, Message: Msg}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"log"
 	"runtime"
+
+	"github.com/atcharles/glibs/trace"
 )
 
 func Recover() Handler {
@@ -21,3 +23,35 @@ func Recover() Handler {
 		c.Next()
 	}
 }
+
+// Tracing starts a span per call, each element of a batch has its own. It's the child of the span of the
+// request (e.g. giris2.Tracing), or of the traceparent header. The methods get the span by their context.Context:
+//
+//	srv.Use("", j2rpc.Tracing(tracer))
+func Tracing(tracer *trace.Tracer) Handler {
+	return func(c Context) {
+		ctx, ok := c.(*rpcContext)
+		if !ok {
+			c.Next()
+			return
+		}
+		parent, kind := ctx.Context, trace.KindInternal
+		if trace.SpanFromContext(parent) == nil {
+			kind = trace.KindServer
+			if ctx.req != nil {
+				parent = trace.Extract(parent, ctx.req.Header)
+			}
+		}
+		method := c.Msg().Method
+		spanCtx, span := tracer.Start(parent, "rpc "+method, kind)
+		span.SetAttr("rpc.system", "jsonrpc").SetAttr("rpc.method", method)
+		ctx.Context = spanCtx
+		defer func() {
+			if e := c.Msg().Error; e != nil {
+				span.SetAttr("rpc.jsonrpc.error_code", int64(e.Code)).SetError(e)
+			}
+			span.Finish()
+		}()
+		c.Next()
+	}
+}
@@ -0,0 +1,59 @@
+package j2rpc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type notifyService struct {
+	calls atomic.Int64
+}
+
+func (s *notifyService) Touch(_ context.Context, n int64) error {
+	s.calls.Add(n)
+	return nil
+}
+
+func (s *notifyService) Fail(_ context.Context) error {
+	s.calls.Add(1)
+	return errors.New("failed")
+}
+
+func TestNotification(t *testing.T) {
+	svc := new(notifyService)
+	s := NewServer()
+	s.RegisterType(svc, "n")
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		calls  int64
+	}{
+		{"notification", `{"jsonrpc":"2.0","method":"n.touch","params":[2]}`, http.StatusNoContent, 2},
+		{"failed notification", `{"jsonrpc":"2.0","method":"n.fail","params":[]}`, http.StatusNoContent, 1},
+		{"unknown method", `{"jsonrpc":"2.0","method":"n.none","params":[]}`, http.StatusNoContent, 0},
+		{"call", `{"jsonrpc":"2.0","id":1,"method":"n.touch","params":[3]}`, http.StatusOK, 3},
+		{"invalid id", `{"jsonrpc":"2.0","id":{},"method":"n.touch","params":[1]}`, http.StatusBadRequest, 0},
+		{"missing method", `{"jsonrpc":"2.0","params":[1]}`, http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc.calls.Store(0)
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body)))
+			require.Equal(t, tt.status, w.Code, w.Body.String())
+			assert.Equal(t, tt.calls, svc.calls.Load())
+			if tt.status == http.StatusNoContent {
+				assert.Empty(t, w.Body.String())
+			}
+		})
+	}
+}
@@ -11,6 +11,13 @@ func IndirectValue(val reflect.Value) reflect.Value {
 	return val
 }
 
+func IndirectType(typ reflect.Type) reflect.Type {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	return typ
+}
+
 func ZeroValue(typ reflect.Type) reflect.Value {
 	if typ == nil {
 		return reflect.Value{}
@@ -0,0 +1,171 @@
+package j2rpc
+
+import (
+	"errors"
+	"log/slog"
+	"reflect"
+	"sort"
+
+	"github.com/atcharles/glibs/util"
+)
+
+// RouteInfo describes a registered method, for the discovery and the documentation
+type RouteInfo struct {
+	Method string
+	//Params the types of the arguments, without receiver and context
+	Params []reflect.Type
+	//ParamNames the names of Params, the params can be sent as a JSON object if they are set
+	ParamNames []string
+	//Result the type of the result, nil if the method returns none, or the type of the items if Stream
+	Result reflect.Type
+	//Generic the method is registered by Handle or HandleStream
+	Generic bool
+	//Stream the method sends the results as Server-Sent Events, see Stream
+	Stream bool
+}
+
+// Handle registers fn as the method, it coexists with the methods of RegisterFunc and RegisterType.
+// The params are decoded into Req, as a JSON object or an array of one element, and validated by its validate tags.
+// fn is called directly without reflect.Value.Call, and Resp is encoded as the result.
+//
+//	j2rpc.Handle(srv, "user.get", func(ctx j2rpc.Context, req GetUserReq) (*User, error) { ... })
+func Handle[Req, Resp any](srv Server, method string, fn func(ctx Context, req Req) (Resp, error)) {
+	route := RouteInfo{
+		Method:  method,
+		Params:  []reflect.Type{reflect.TypeOf((*Req)(nil)).Elem()},
+		Result:  reflect.TypeOf((*Resp)(nil)).Elem(),
+		Generic: true,
+	}
+	decode := newRequestDecoder[Req]()
+	srv.RegisterRoute(route, func(c Context) {
+		defer c.Next()
+		if c.Wrote() {
+			return
+		}
+		req, ok := decode(c)
+		if !ok {
+			return
+		}
+		resp, err := fn(c, req)
+		if err != nil {
+			c.WriteResponse(err)
+			return
+		}
+		if isNilValue(reflect.ValueOf(resp)) {
+			c.WriteResponse()
+			return
+		}
+		c.WriteResponse(resultValue{resp})
+	})
+}
+
+// newRequestDecoder decodes and validates the params of the call into Req, it writes the error if it fails
+func newRequestDecoder[Req any]() func(c Context) (Req, bool) {
+	reqType := reflect.TypeOf((*Req)(nil)).Elem()
+	validate := IndirectType(reqType).Kind() == reflect.Struct && util.HasValidateTag(reqType)
+	reqIsPtr := reqType.Kind() == reflect.Ptr
+	return func(c Context) (req Req, ok bool) {
+		if err := decodeParams(requestCodecOf(c), c.Msg().Params, &req); err != nil {
+			c.WriteResponse(NewError(ErrBadParams, err.Error()))
+			return
+		}
+		if opt := c.Server().Option(); validate && !opt.DisableValidation && !(reqIsPtr && reflect.ValueOf(req).IsNil()) {
+			if e := validateValue(opt.validator(), req); e != nil {
+				c.WriteResponse(e)
+				return
+			}
+		}
+		return req, true
+	}
+}
+
+// Routes returns the registered methods sorted by name
+func (s *server) Routes() []RouteInfo { return s.router.routeInfos() }
+
+// decodeParams decodes an object, or the only element of an array, encoded by the codec into val.
+// No params leaves val zero.
+func decodeParams(codec Codec, params RawMessage, val interface{}) error {
+	switch wireKind(codec, params) {
+	case 'n':
+		return nil
+	case '[':
+		elements, err := wireArray(codec, params)
+		if err != nil || len(elements) == 0 {
+			return err
+		}
+		if len(elements) > 1 {
+			return errors.New("too many arguments, want at most 1")
+		}
+		return codec.Unmarshal(elements[0], val)
+	}
+	return codec.Unmarshal(params, val)
+}
+
+// isNilValue the value is encoded as null
+func isNilValue(v reflect.Value) bool {
+	if !v.IsValid() {
+		return true
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+		return v.IsNil()
+	}
+	return false
+}
+
+// addRoute adds the route of Handle, false if the method exists
+func (r *rpcRouter) addRoute(route RouteInfo) bool {
+	r.lazyInit()
+	if r.has(route.Method) {
+		slog.Warn("Skip existing methodName", slog.String("methodName", route.Method))
+		return false
+	}
+	r.routes[route.Method] = route
+	return true
+}
+
+// has ...
+func (r *rpcRouter) has(method string) bool {
+	if _, ok := r.funcs[method]; ok {
+		return true
+	}
+	_, ok := r.routes[method]
+	return ok
+}
+
+// routeInfos the routes of Handle and the reflective methods
+func (r *rpcRouter) routeInfos() []RouteInfo {
+	r.lazyInit()
+	routes := make([]RouteInfo, 0, len(r.funcs)+len(r.routes))
+	for _, route := range r.routes {
+		routes = append(routes, route)
+	}
+	for _, f := range r.funcs {
+		routes = append(routes, f.routeInfo())
+	}
+	sort.Slice(routes, func(i, j int) bool { return routes[i].Method < routes[j].Method })
+	return routes
+}
+
+// routeInfo ...
+func (f funcInfo) routeInfo() RouteInfo {
+	params := f.argTs
+	if f.isType && len(params) > 0 {
+		params = params[1:]
+	}
+	if len(params) > 0 && params[0].Implements(contextType) {
+		params = params[1:]
+	}
+	route := RouteInfo{Method: f.name, Params: params, ParamNames: f.argNames}
+	if len(params) > 0 && params[0] == streamType {
+		route.Params, route.Stream = params[1:], true
+	}
+	fnt := f.fn.Type()
+	if fnt.NumOut() > 0 && !fnt.Out(0).Implements(errorType) {
+		route.Result = fnt.Out(0)
+	}
+	if route.Result != nil && route.Result.Kind() == reflect.Chan {
+		route.Result, route.Stream = route.Result.Elem(), true
+	}
+	return route
+}
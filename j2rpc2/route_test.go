@@ -0,0 +1,68 @@
+package j2rpc
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type handleReq struct {
+	A int    `json:"a" validate:"gte=0"`
+	B string `json:"b"`
+}
+
+func TestHandle(t *testing.T) {
+	s := NewServer()
+	Handle(s, "h.echo", func(_ Context, req handleReq) (*handleReq, error) {
+		if req.B == "fail" {
+			return nil, errors.New("failed")
+		}
+		if req.B == "nil" {
+			return nil, nil
+		}
+		return &req, nil
+	})
+	tests := []struct {
+		name   string
+		params string
+		// want the result, or the error code when it's not 0
+		want string
+		code ErrorCode
+	}{
+		{"object", `{"a":1,"b":"x"}`, `{"a":1,"b":"x"}`, 0},
+		{"array", `[{"a":2}]`, `{"a":2,"b":""}`, 0},
+		{"empty array", `[]`, `{"a":0,"b":""}`, 0},
+		{"null", `null`, `{"a":0,"b":""}`, 0},
+		{"extra element", `[{"a":1},"junk"]`, "", ErrBadParams},
+		{"wrong type", `[{"a":"x"}]`, "", ErrBadParams},
+		{"invalid", `[{"a":-1}]`, "", ErrBadParams},
+		{"error", `{"b":"fail"}`, "", ErrInternal},
+		{"nil result", `{"b":"nil"}`, `"Success"`, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `{"jsonrpc":"2.0","id":1,"method":"h.echo","params":` + tt.params + `}`
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+			require.Equal(t, http.StatusOK, w.Code)
+			var resp struct {
+				Result json.RawMessage `json:"result"`
+				Error  *Error          `json:"error"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp), w.Body.String())
+			if tt.code != 0 {
+				require.NotNil(t, resp.Error, w.Body.String())
+				assert.Equal(t, tt.code, resp.Error.Code)
+				return
+			}
+			require.Nil(t, resp.Error, w.Body.String())
+			assert.JSONEq(t, tt.want, string(resp.Result))
+		})
+	}
+}
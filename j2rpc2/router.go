@@ -7,6 +7,9 @@ import (
 	"runtime"
 	"strings"
 	"sync"
+
+	v1 "github.com/atcharles/glibs/j2rpc"
+	"github.com/atcharles/glibs/util"
 )
 
 const Separator = "."
@@ -24,23 +27,51 @@ type ImplRPCTypeName interface {
 	RPCTypeName() string
 }
 
+// ImplRPCParamNames declares the argument names (without receiver, context and Stream) of a method,
+// then the params can be sent as a JSON object
+type ImplRPCParamNames interface {
+	RPCParamNames(methodName string) []string
+}
+
+// hookMethods are the methods of the optional interfaces, they are never registered as rpc methods
+var hookMethods = map[string]bool{
+	"RPCMethodProvider": true,
+	"RPCTypeName":       true,
+	"RPCParamNames":     true,
+	"J2rpcSkipValidate": true,
+	"RPCCachePolicy":    true,
+}
+
+// ImplRPCSkipValidate declares the methods whose arguments are not validated,
+// it's the one of j2rpc, so a type opts out on both servers
+type ImplRPCSkipValidate = v1.ItfSkipValidate
+
 type funcInfo struct {
 	isType bool
 	name   string
 	fn     reflect.Value
 	args   []reflect.Value
 	argTs  []reflect.Type
+	//argNames maps the by-name params to args
+	argNames []string
+	//skipValidate skips the validation of arguments
+	skipValidate bool
+	//cache the policy declared by ImplRPCCachePolicy
+	cache *CachePolicy
 }
 
 type rpcRouter struct {
 	funcs map[string]funcInfo
-	once  sync.Once
+	//routes the methods registered by Handle
+	routes map[string]RouteInfo
+	once   sync.Once
 }
 
 // lazyInit ...
 func (r *rpcRouter) lazyInit() *rpcRouter {
 	r.once.Do(func() {
 		r.funcs = make(map[string]funcInfo)
+		r.routes = make(map[string]RouteInfo)
 	})
 	return r
 }
@@ -67,7 +98,7 @@ func (r *rpcRouter) registerFunc(val interface{}, methodName string, callback fu
 		methodName = methodName[strings.LastIndex(methodName, Separator)+1:]
 		methodName = MethodNameProvider(methodName)
 	}
-	if _, has := r.funcs[methodName]; has {
+	if r.has(methodName) {
 		slog.Warn("Skip existing methodName", slog.String("methodName", methodName))
 		return
 	}
@@ -101,11 +132,14 @@ func (r *rpcRouter) registerType(val interface{}, typeName string, callback func
 	numMethod := vType.NumMethod()
 	for i := 0; i < numMethod; i++ {
 		m := vType.Method(i)
+		if hookMethods[m.Name] {
+			continue
+		}
 		methodName := fmt.Sprintf("%s%s%s", typeName, Separator, MethodNameProvider(m.Name))
 		if _v, ok := val.(ImplRPCMethodProvider); ok {
 			methodName = _v.RPCMethodProvider(m.Name)
 		}
-		if _, has := r.funcs[methodName]; has {
+		if r.has(methodName) {
 			slog.Warn("Skip existing methodName", slog.String("methodName", methodName))
 			continue
 		}
@@ -123,6 +157,15 @@ func (r *rpcRouter) registerType(val interface{}, typeName string, callback func
 			args:   args,
 			argTs:  argTs,
 		}
+		if _v, ok := val.(ImplRPCParamNames); ok {
+			info.argNames = _v.RPCParamNames(m.Name)
+		}
+		if _v, ok := val.(ImplRPCSkipValidate); ok {
+			info.skipValidate = util.Contains(_v.J2rpcSkipValidate(), m.Name)
+		}
+		if _v, ok := val.(ImplRPCCachePolicy); ok {
+			info.cache = _v.RPCCachePolicy(m.Name)
+		}
 		r.funcs[methodName] = info
 		if callback != nil {
 			callback(info)
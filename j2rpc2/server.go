@@ -3,8 +3,13 @@ package j2rpc
 import (
 	"net/http"
 	"reflect"
+	"sort"
 	"strings"
 	"time"
+
+	v1 "github.com/atcharles/glibs/j2rpc"
+	"github.com/atcharles/glibs/mdb"
+	"github.com/atcharles/glibs/util"
 )
 
 type CallerBody func([]byte) ([]byte, error)
@@ -14,6 +19,28 @@ type Option func(option *ServerOption)
 type ServerOption struct {
 	CallerAfterReadBody CallerBody
 	CallerBeforeWrite   CallerBody
+	//ErrorRegistry maps the errors to JSON-RPC errors, default is j2rpc.DefaultErrorRegistry
+	ErrorRegistry *v1.ErrorRegistry
+	//Validator validates the struct arguments with validate tags, default is util.ValidatorInc
+	Validator *util.Validator
+	//DisableValidation disables the validation of arguments
+	DisableValidation bool
+	//BatchLimit max number of elements in a batch request, default is 0 (no limit)
+	BatchLimit int
+	//CachePolicies the cacheable methods, they can be called by GET, see CachePolicy
+	CachePolicies map[string]*CachePolicy
+	//CacheStore stores the responses of the cacheable methods, nil stores none
+	CacheStore mdb.CacheStore
+	//Codecs the wire codecs, default are JSONCodec, MsgpackCodec and CBORCodec, see Codec
+	Codecs []Codec
+}
+
+// validator ...
+func (o *ServerOption) validator() *util.Validator {
+	if o.Validator == nil {
+		return util.ValidatorInc
+	}
+	return o.Validator
 }
 
 type server struct {
@@ -33,6 +60,15 @@ func (s *server) Handler(c Context) {
 
 func (s *server) Option() *ServerOption { return s.option }
 
+// RegisterRoute registers the handlers of a method which is dispatched without reflection, see Handle.
+// It's skipped if the method exists.
+func (s *server) RegisterRoute(route RouteInfo, handlers ...Handler) {
+	if !s.router.addRoute(route) {
+		return
+	}
+	s.Use(route.Method, handlers...)
+}
+
 func (s *server) RegisterFunc(args ...interface{}) {
 	name, fn := argsToNameInterface(args...)
 	s.router.registerFunc(fn, name, func(f funcInfo) { s.Use(f.name, s.handleCallFunc(f)) })
@@ -40,7 +76,12 @@ func (s *server) RegisterFunc(args ...interface{}) {
 
 func (s *server) RegisterType(args ...interface{}) {
 	name, bean := argsToNameInterface(args...)
-	s.router.registerType(bean, name, func(f funcInfo) { s.Use(f.name, s.handleCallFunc(f)) })
+	s.router.registerType(bean, name, func(f funcInfo) {
+		if f.cache != nil && s.option.cachePolicy(f.name) == nil {
+			WithCachePolicy(*f.cache, f.name)(s.option)
+		}
+		s.Use(f.name, s.handleCallFunc(f))
+	})
 }
 
 // RegisterTypeBus reg type bus
@@ -114,39 +155,74 @@ func (s *server) handleCallFunc(f funcInfo) Handler {
 			argTypes = argTypes[1:]
 			argValues = append(argValues, ctxVal)
 		}
-		values, err := parsePositionalArguments(c.Msg().Params, argTypes)
+		var stream *sseStream
+		if len(argTypes) > 0 && argTypes[0] == streamType {
+			var e error
+			if stream, e = newStream(c); e != nil {
+				c.WriteResponse(e)
+				return
+			}
+			argTypes = argTypes[1:]
+			argValues = append(argValues, reflect.ValueOf(stream))
+		}
+		values, err := parseArguments(requestCodecOf(c), c.Msg().Params, argTypes, f.argNames)
 		if err != nil {
 			c.WriteResponse(NewError(ErrBadParams, err.Error()))
 			return
 		}
+		if !f.skipValidate && !s.option.DisableValidation {
+			if e := validateArguments(s.validator(), values); e != nil {
+				c.WriteResponse(e)
+				return
+			}
+		}
 		argValues = append(argValues, values...)
+		if stream == nil && f.streamResult() {
+			var e error
+			if stream, e = newStream(c); e != nil {
+				c.WriteResponse(e)
+				return
+			}
+		}
+		write := c.WriteResponse
+		if stream != nil {
+			write = stream.finish
+		}
 		results := f.fn.Call(argValues)
 		if len(results) > 0 {
 			last := results[len(results)-1]
 			if last.Type().Implements(errorType) {
 				if !last.IsNil() {
-					c.WriteResponse(last.Interface().(error))
+					write(last.Interface().(error))
 					return
 				}
 				results = results[:len(results)-1]
 			}
 		}
 		if len(results) == 0 {
-			c.WriteResponse()
+			write()
 			return
 		}
 		ret := results[0]
+		if ret.Kind() == reflect.Chan && stream != nil {
+			stream.forward(ret)
+			return
+		}
 		if (ret.Kind() == reflect.Interface ||
 			ret.Kind() == reflect.Ptr) && ret.IsNil() {
-			c.WriteResponse()
+			write()
+			return
+		}
+		if stream == nil {
+			write(resultValue{ret.Interface()})
 			return
 		}
 		data, e := JSONEncode(ret.Interface())
 		if e != nil {
-			c.WriteResponse(NewError(ErrInternal, e.Error()))
+			write(NewError(ErrInternal, e.Error()))
 			return
 		}
-		c.WriteResponse(data)
+		write(data)
 	}
 }
 
@@ -163,6 +239,11 @@ func (s *server) handleReadBody() Handler {
 		if c.Wrote() {
 			return
 		}
+		if elements := ctx.batchElements(); elements != nil {
+			c.Abort()
+			s.handleBatch(ctx, elements)
+			return
+		}
 		c.SetValue(TimeBeginContextKey, time.Now())
 		method := ctx.Msg().Method
 		group, has := s.groups[method]
@@ -170,30 +251,55 @@ func (s *server) handleReadBody() Handler {
 			c.WriteResponse(NewError(ErrNoMethod, "wrong method"))
 			return
 		}
-		handlers := make([]Handler, 0)
-		for _key, _group := range s.groups {
-			if _key != "" && method != _key && strings.HasPrefix(method, _key+Separator) {
-				handlers = append(handlers, _group.Handlers()...)
-			}
+		c.AddHandler(s.groupHandlers(method, group)...)
+	}
+}
+
+// groupHandlers the handlers of the groups matching the method, from the most general to the most specific,
+// then the handlers of the method's own group
+func (s *server) groupHandlers(method string, group Group) []Handler {
+	segments := strings.Split(method, Separator)
+	paths := make([][]string, 0)
+	for _key := range s.groups {
+		if _key == "" || _key == method {
+			continue
+		}
+		if path := strings.Split(_key, Separator); groupMatch(path, segments) {
+			paths = append(paths, path)
 		}
-		handlers = append(handlers, group.Handlers()...)
-		c.AddHandler(handlers...)
 	}
+	sort.Slice(paths, func(i, j int) bool { return groupLess(paths[i], paths[j]) })
+	handlers := make([]Handler, 0)
+	for _, path := range paths {
+		handlers = append(handlers, s.groups[strings.Join(path, Separator)].Handlers()...)
+	}
+	if s.option.cachePolicy(method) != nil {
+		handlers = append(handlers, s.handleCache())
+	}
+	return append(handlers, group.Handlers()...)
 }
 
+func (s *server) validator() *util.Validator { return s.option.validator() }
+
 func NewServer(opt ...Option) Server {
 	s := &server{
 		groups: make(map[string]Group),
 		router: &rpcRouter{},
 	}
 	s.Use("", s.handleReadBody())
-	s.option = &ServerOption{}
+	s.option = &ServerOption{Codecs: []Codec{JSONCodec, MsgpackCodec, CBORCodec}}
 	for _, o := range opt {
 		o(s.option)
 	}
 	return s
 }
 
+func WithBatchLimit(limit int) Option {
+	return func(option *ServerOption) {
+		option.BatchLimit = limit
+	}
+}
+
 func WithCallerAfterReadBody(fn CallerBody) Option {
 	return func(option *ServerOption) {
 		option.CallerAfterReadBody = fn
@@ -205,3 +311,15 @@ func WithCallerBeforeWrite(fn CallerBody) Option {
 		option.CallerBeforeWrite = fn
 	}
 }
+
+func WithErrorRegistry(registry *v1.ErrorRegistry) Option {
+	return func(option *ServerOption) {
+		option.ErrorRegistry = registry
+	}
+}
+
+func WithValidator(validator *util.Validator) Option {
+	return func(option *ServerOption) {
+		option.Validator = validator
+	}
+}
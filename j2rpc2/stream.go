@@ -0,0 +1,223 @@
+package j2rpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"strconv"
+	"sync"
+)
+
+var streamType = reflect.TypeOf((*Stream)(nil)).Elem()
+
+// Stream sends the progressive results of a method as Server-Sent Events (text/event-stream).
+// A method takes it as the argument after the context, or returns a channel whose items are sent.
+// Each item is an event of a JSON-RPC notification with the request id:
+//
+//	data: {"jsonrpc":"2.0","id":1,"method":"export.run","params":<item>}
+//
+// and the call ends with an event "result" or "error" of the response.
+// The context is cancelled when the client disconnects, then Send returns its error. The events are JSON whatever the Codec.
+type Stream interface {
+	Context() context.Context
+	Send(item interface{}) error
+}
+
+// sseStream the Stream of a call, the headers are written at the first event
+type sseStream struct {
+	mu      sync.Mutex
+	c       *rpcContext
+	msg     *RPCMessage
+	started bool
+	closed  bool
+	seq     int
+}
+
+// Context ...
+func (s *sseStream) Context() context.Context { return s.c }
+
+// Send ...
+func (s *sseStream) Send(item interface{}) error {
+	if err := s.c.Err(); err != nil {
+		return err
+	}
+	params, err := JSONEncode(item)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return errors.New("stream is closed")
+	}
+	params = s.c.fields(s.msg).Project(bytes.TrimSpace(params))
+	return s.writeEvent("", &RPCMessage{ID: s.msg.ID, Version: "2.0", Method: s.msg.Method, Params: params})
+}
+
+// finish ends the stream with the response, the args are the ones of Context.WriteResponse
+func (s *sseStream) finish(args ...interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	msg := s.c.Msg()
+	for _, arg := range args {
+		switch _arg := arg.(type) {
+		case error:
+			msg.Error = s.c.mapError(_arg)
+		case []byte:
+			msg.Result = _arg
+		case RawMessage:
+			msg.Result = _arg
+		}
+	}
+	if err := s.c.Err(); err != nil && msg.Error == nil {
+		msg.Error = NewError(ErrServer, err.Error())
+	}
+	if msg.Error == nil && msg.Result == nil {
+		msg.Result, _ = JSONEncode("Success")
+	}
+	event := "result"
+	if msg.Error != nil {
+		msg.Result, event = nil, "error"
+	} else {
+		msg.Result = s.c.fields(msg).Project(msg.Result)
+	}
+	s.c.SetMsg(msg)
+	defer s.c.SetWrote(true)
+	if s.c.Err() != nil {
+		//the client is gone
+		return
+	}
+	_ = s.writeEvent(event, &RPCMessage{ID: msg.ID, Version: "2.0", Result: msg.Result, Error: msg.Error})
+}
+
+// forward sends the items of the channel until it's closed, an error item ends the stream with the error
+func (s *sseStream) forward(ch reflect.Value) {
+	if ch.IsNil() {
+		s.finish()
+		return
+	}
+	cases := []reflect.SelectCase{
+		{Dir: reflect.SelectRecv, Chan: ch},
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(s.c.Done())},
+	}
+	for {
+		chosen, item, ok := reflect.Select(cases)
+		switch {
+		case chosen == 1:
+			s.finish()
+			return
+		case !ok:
+			s.finish()
+			return
+		}
+		if err, isErr := item.Interface().(error); isErr && err != nil {
+			s.finish(err)
+			return
+		}
+		if err := s.Send(item.Interface()); err != nil {
+			s.finish(err)
+			return
+		}
+	}
+}
+
+// writeEvent writes the message as an event and flushes it, the data of CallerBeforeWrite may have several lines
+func (s *sseStream) writeEvent(event string, msg *RPCMessage) error {
+	data, err := JSONEncode(msg)
+	if err != nil {
+		return err
+	}
+	data = bytes.TrimSpace(data)
+	if callerBeforeWrite := s.c.Server().Option().CallerBeforeWrite; callerBeforeWrite != nil {
+		if data, err = callerBeforeWrite(data); err != nil {
+			return err
+		}
+	}
+	w := s.c.Writer()
+	if !s.started {
+		s.started = true
+		w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+	}
+	s.seq++
+	buf := new(bytes.Buffer)
+	if len(event) > 0 {
+		buf.WriteString("event: " + event + "\n")
+	}
+	buf.WriteString("id: " + strconv.Itoa(s.seq) + "\n")
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		buf.WriteString("data: ")
+		buf.Write(line)
+		buf.WriteString("\n")
+	}
+	buf.WriteString("\n")
+	if _, err = w.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return nil
+}
+
+// HandleStream registers fn as a streaming method like Handle, fn sends the items by send,
+// and its error ends the stream. See Stream.
+func HandleStream[Req, Item any](srv Server, method string, fn func(ctx Context, req Req, send func(item Item) error) error) {
+	reqType := reflect.TypeOf((*Req)(nil)).Elem()
+	route := RouteInfo{
+		Method:  method,
+		Params:  []reflect.Type{reqType},
+		Result:  reflect.TypeOf((*Item)(nil)).Elem(),
+		Generic: true,
+		Stream:  true,
+	}
+	decode := newRequestDecoder[Req]()
+	srv.RegisterRoute(route, func(c Context) {
+		defer c.Next()
+		if c.Wrote() {
+			return
+		}
+		req, ok := decode(c)
+		if !ok {
+			return
+		}
+		stream, err := newStream(c)
+		if err != nil {
+			c.WriteResponse(err)
+			return
+		}
+		err = fn(c, req, func(item Item) error { return stream.Send(item) })
+		if err != nil {
+			stream.finish(err)
+			return
+		}
+		stream.finish()
+	})
+}
+
+// streamResult the method returns a channel
+func (f funcInfo) streamResult() bool {
+	fnt := f.fn.Type()
+	return fnt.NumOut() > 0 && fnt.Out(0).Kind() == reflect.Chan
+}
+
+// newStream the Stream of the call, a batch element can't be streamed
+func newStream(c Context) (*sseStream, error) {
+	ctx, ok := c.(*rpcContext)
+	if !ok {
+		return nil, NewError(ErrInternal, "context error")
+	}
+	if _, ok = ctx.Writer().(*batchWriter); ok {
+		return nil, NewError(ErrInvalidRequest, "streaming method can't be called in a batch")
+	}
+	return &sseStream{c: ctx, msg: ctx.Msg()}, nil
+}
@@ -0,0 +1,182 @@
+package j2rpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type (
+	streamService struct {
+		sent atomic.Int64
+	}
+
+	streamItem struct {
+		I    int    `json:"i"`
+		Name string `json:"name"`
+	}
+
+	// sseEvent an event of the response, Data is the message
+	sseEvent struct {
+		Event string
+		ID    string
+		Data  *RPCMessage
+	}
+)
+
+// Count sends n items, it fails after the first item if n is negative
+func (s *streamService) Count(ctx context.Context, st Stream, n int) (int, error) {
+	if n < 0 {
+		_ = st.Send(streamItem{I: 0, Name: "item"})
+		return 0, errors.New("count failed")
+	}
+	for i := 0; i < n; i++ {
+		if err := st.Send(streamItem{I: i, Name: "item"}); err != nil {
+			return 0, err
+		}
+		s.sent.Add(1)
+	}
+	return n, nil
+}
+
+// Chan sends n items by a channel, then the error if fail is set
+func (s *streamService) Chan(_ context.Context, n int, fail bool) (<-chan interface{}, error) {
+	ch := make(chan interface{}, n+1)
+	for i := 0; i < n; i++ {
+		ch <- streamItem{I: i, Name: "item"}
+	}
+	if fail {
+		ch <- errors.New("chan failed")
+	}
+	close(ch)
+	return ch, nil
+}
+
+// parseSSE parses the events of the body
+func parseSSE(t *testing.T, body string) []sseEvent {
+	t.Helper()
+	var events []sseEvent
+	for _, block := range strings.Split(strings.TrimSpace(body), "\n\n") {
+		var event sseEvent
+		var data string
+		for _, line := range strings.Split(block, "\n") {
+			key, value, _ := strings.Cut(line, ": ")
+			switch key {
+			case "event":
+				event.Event = value
+			case "id":
+				event.ID = value
+			case "data":
+				data += value
+			}
+		}
+		event.Data = new(RPCMessage)
+		require.NoError(t, json.Unmarshal([]byte(data), event.Data), block)
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestStream(t *testing.T) {
+	svc := new(streamService)
+	s := NewServer()
+	s.RegisterType(svc, "export")
+	HandleStream(s, "export.generic", func(_ Context, req streamItem, send func(item streamItem) error) error {
+		for i := 0; i < req.I; i++ {
+			if err := send(streamItem{I: i, Name: req.Name}); err != nil {
+				return err
+			}
+		}
+		if req.Name == "fail" {
+			return errors.New("generic failed")
+		}
+		return nil
+	})
+	tests := []struct {
+		name   string
+		method string
+		params string
+		fields string
+		// items the params of the item events
+		items []string
+		// event the last event, result or error
+		event  string
+		result string
+	}{
+		{"stream", "export.count", `[2]`, "", []string{`{"i":0,"name":"item"}`, `{"i":1,"name":"item"}`}, "result", `2`},
+		{"stream error", "export.count", `[-1]`, "", []string{`{"i":0,"name":"item"}`}, "error", ""},
+		{"fields", "export.count", `[1]`, `["i"]`, []string{`{"i":0}`}, "result", `1`},
+		{"chan", "export.chan", `[2, false]`, "", []string{`{"i":0,"name":"item"}`, `{"i":1,"name":"item"}`}, "result", `"Success"`},
+		{"chan error", "export.chan", `[1, true]`, "", []string{`{"i":0,"name":"item"}`}, "error", ""},
+		{"generic", "export.generic", `{"i":2,"name":"g"}`, "", []string{`{"i":0,"name":"g"}`, `{"i":1,"name":"g"}`}, "result", `"Success"`},
+		{"generic error", "export.generic", `{"i":0,"name":"fail"}`, "", nil, "error", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `{"jsonrpc":"2.0","id":7,"method":"` + tt.method + `","params":` + tt.params
+			if len(tt.fields) > 0 {
+				body += `,"fields":` + tt.fields
+			}
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body+"}")))
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+			assert.Equal(t, "text/event-stream; charset=utf-8", w.Header().Get("Content-Type"))
+			assert.Equal(t, "no-cache", w.Header().Get("Cache-Control"))
+
+			events := parseSSE(t, w.Body.String())
+			require.Len(t, events, len(tt.items)+1, w.Body.String())
+			for i, item := range tt.items {
+				event := events[i]
+				assert.Empty(t, event.Event)
+				assert.Equal(t, tt.method, event.Data.Method)
+				assert.Equal(t, "7", string(event.Data.ID))
+				assert.JSONEq(t, item, string(event.Data.Params))
+			}
+			last := events[len(events)-1]
+			assert.Equal(t, tt.event, last.Event)
+			assert.Equal(t, "7", string(last.Data.ID))
+			assert.Empty(t, last.Data.Method)
+			for i, event := range events {
+				assert.Equal(t, strconv.Itoa(i+1), event.ID)
+			}
+			if tt.event == "error" {
+				require.NotNil(t, last.Data.Error)
+				assert.Empty(t, last.Data.Result)
+				return
+			}
+			assert.Nil(t, last.Data.Error)
+			assert.JSONEq(t, tt.result, string(last.Data.Result))
+		})
+	}
+
+	// a streaming method can't be called in a batch
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/",
+		strings.NewReader(`[{"jsonrpc":"2.0","id":1,"method":"export.count","params":[1]}]`)))
+	require.Equal(t, http.StatusOK, w.Code)
+	var batch []*RPCMessage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &batch), w.Body.String())
+	require.Len(t, batch, 1)
+	require.NotNil(t, batch[0].Error)
+	assert.Equal(t, ErrInvalidRequest, batch[0].Error.Code)
+
+	// the items aren't sent to a gone client
+	svc.sent.Store(0)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/",
+		strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"export.count","params":[3]}`)).WithContext(ctx)
+	s.ServeHTTP(w, r)
+	assert.Zero(t, svc.sent.Load())
+	assert.NotContains(t, w.Body.String(), "event: result")
+}
@@ -0,0 +1,344 @@
+package j2rpc
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/atcharles/glibs/util"
+)
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	jsonTimeType      = reflect.TypeOf(util.JSONTime{})
+	jsonRawType       = reflect.TypeOf(json.RawMessage{})
+	rawMessageType    = reflect.TypeOf(RawMessage{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// tsTransport the transport and the error of the generated clients
+const tsTransport = `export interface RpcTransport {
+  call<T>(method: string, params: unknown[]): Promise<T>;
+}
+
+export class RpcError extends Error {
+  constructor(
+    public readonly code: number,
+    message: string,
+    public readonly data?: unknown,
+  ) {
+    super(message);
+  }
+}
+
+// httpTransport posts the calls to the j2rpc endpoint
+export function httpTransport(url: string, init?: RequestInit): RpcTransport {
+  let id = 0;
+  return {
+    async call<T>(method: string, params: unknown[]): Promise<T> {
+      const resp = await fetch(url, {
+        ...init,
+        method: "POST",
+        headers: { "Content-Type": "application/json", ...init?.headers },
+        body: JSON.stringify({ jsonrpc: "2.0", id: ++id, method, params }),
+      });
+      const msg = await resp.json();
+      if (msg.error) {
+        throw new RpcError(msg.error.code, msg.error.message, msg.error.data);
+      }
+      return msg.result as T;
+    },
+  };
+}
+`
+
+// tsGenerator collects the interfaces of the Go structs used by the routes
+type tsGenerator struct {
+	//names the interface name of each struct
+	names map[reflect.Type]string
+	//taken the struct of each interface name
+	taken map[string]reflect.Type
+	//decls the interfaces
+	decls map[string]string
+}
+
+// WriteTypeScript writes the TypeScript interfaces of the params and results of the routes,
+// and an async client class per namespace. The ts command of cmd2 runs it, e.g. by go generate:
+//
+//	//go:generate go run . ts -o web/src/api/rpc.ts
+func WriteTypeScript(w io.Writer, routes []RouteInfo) error {
+	g := &tsGenerator{
+		names: make(map[reflect.Type]string),
+		taken: make(map[string]reflect.Type),
+		decls: make(map[string]string),
+	}
+	namespaces := make(map[string][]string)
+	for _, route := range routes {
+		namespace, name := route.Method, route.Method
+		if i := strings.LastIndex(route.Method, Separator); i >= 0 {
+			namespace, name = route.Method[:i], route.Method[i+1:]
+		} else {
+			namespace = ""
+		}
+		if route.Stream {
+			//the transport can't read the events, the type of the items is declared only
+			item := g.typeOf(route.Result)
+			if strings.Contains(item, "\n") {
+				item = "object"
+			}
+			namespaces[namespace] = append(namespaces[namespace], fmt.Sprintf("  // %s streams Server-Sent Events of %s\n", name, item))
+			continue
+		}
+		namespaces[namespace] = append(namespaces[namespace], g.method(route, name))
+	}
+
+	buf := new(bytes.Buffer)
+	buf.WriteString("// Code generated by j2rpc.WriteTypeScript. DO NOT EDIT.\n\n")
+	buf.WriteString(tsTransport)
+	declNames := make([]string, 0, len(g.decls))
+	for name := range g.decls {
+		declNames = append(declNames, name)
+	}
+	sort.Strings(declNames)
+	for _, name := range declNames {
+		buf.WriteString("\n")
+		buf.WriteString(g.decls[name])
+	}
+
+	nsNames := make([]string, 0, len(namespaces))
+	for namespace := range namespaces {
+		nsNames = append(nsNames, namespace)
+	}
+	sort.Strings(nsNames)
+	for _, namespace := range nsNames {
+		fmt.Fprintf(buf, "\nexport class %s {\n", tsClientName(namespace))
+		buf.WriteString("  constructor(private readonly transport: RpcTransport) {}\n")
+		for _, method := range namespaces[namespace] {
+			buf.WriteString("\n")
+			buf.WriteString(method)
+		}
+		buf.WriteString("}\n")
+	}
+
+	buf.WriteString("\nexport class RpcClient {\n")
+	for _, namespace := range nsNames {
+		fmt.Fprintf(buf, "  readonly %s: %s;\n", tsFieldName(namespace), tsClientName(namespace))
+	}
+	buf.WriteString("\n  constructor(transport: RpcTransport) {\n")
+	for _, namespace := range nsNames {
+		fmt.Fprintf(buf, "    this.%s = new %s(transport);\n", tsFieldName(namespace), tsClientName(namespace))
+	}
+	buf.WriteString("  }\n}\n")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// GenerateTypeScript the TypeScript of the routes of srv, see WriteTypeScript
+func GenerateTypeScript(srv Server) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := WriteTypeScript(buf, srv.Routes()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// method the method of the client class, the params are sent positionally and the trailing pointers are optional
+func (g *tsGenerator) method(route RouteInfo, name string) string {
+	args := make([]string, len(route.Params))
+	values := make([]string, len(route.Params))
+	optional := true
+	for i := len(route.Params) - 1; i >= 0; i-- {
+		typ := route.Params[i]
+		argName := fmt.Sprintf("arg%d", i)
+		if len(route.ParamNames) == len(route.Params) && tsIdentifier(route.ParamNames[i]) {
+			argName = route.ParamNames[i]
+		}
+		optional = optional && typ.Kind() == reflect.Ptr
+		if optional {
+			args[i] = argName + "?: " + g.typeOf(typ)
+		} else {
+			args[i] = argName + ": " + g.typeOf(typ)
+		}
+		values[i] = argName
+	}
+	result := "void"
+	if route.Result != nil {
+		result = g.typeOf(route.Result)
+	}
+	return fmt.Sprintf("  async %s(%s): Promise<%s> {\n    return this.transport.call<%s>(%q, [%s]);\n  }\n",
+		name, strings.Join(args, ", "), result, result, route.Method, strings.Join(values, ", "))
+}
+
+// typeOf the TypeScript type of typ, the structs are declared as interfaces
+func (g *tsGenerator) typeOf(typ reflect.Type) string {
+	typ = IndirectType(typ)
+	switch {
+	case typ == timeType || typ == jsonTimeType:
+		return "string"
+	case typ == jsonRawType || typ == rawMessageType:
+		return "unknown"
+	case typ.Implements(jsonMarshalerType) || reflect.PointerTo(typ).Implements(jsonMarshalerType):
+		return "unknown"
+	case typ.Implements(textMarshalerType) || reflect.PointerTo(typ).Implements(textMarshalerType):
+		return "string"
+	}
+	switch typ.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	case reflect.Slice, reflect.Array:
+		if typ.Elem().Kind() == reflect.Uint8 && typ.Kind() == reflect.Slice {
+			//base64
+			return "string"
+		}
+		elem := g.typeOf(typ.Elem())
+		if strings.ContainsAny(elem, " |") {
+			elem = "(" + elem + ")"
+		}
+		return elem + "[]"
+	case reflect.Map:
+		return "Record<string, " + g.typeOf(typ.Elem()) + ">"
+	case reflect.Struct:
+		if len(typ.Name()) == 0 {
+			return g.structBody(typ, "")
+		}
+		return g.declare(typ)
+	}
+	return "unknown"
+}
+
+// declare declares the interface of the named struct once, and returns its name
+func (g *tsGenerator) declare(typ reflect.Type) string {
+	if name, ok := g.names[typ]; ok {
+		return name
+	}
+	name := tsTypeName(typ)
+	if _, ok := g.taken[name]; ok {
+		//the same name in another package
+		pkg := typ.PkgPath()[strings.LastIndex(typ.PkgPath(), "/")+1:]
+		name = tsTypeName(typ, pkg)
+		for i := 2; g.taken[name] != nil; i++ {
+			name = fmt.Sprintf("%s%d", tsTypeName(typ, pkg), i)
+		}
+	}
+	g.names[typ], g.taken[name] = name, typ
+	g.decls[name] = fmt.Sprintf("export interface %s %s\n", name, g.structBody(typ, ""))
+	return name
+}
+
+// structBody the fields of the struct by their json names, the pointers and omitempty are optional
+func (g *tsGenerator) structBody(typ reflect.Type, indent string) string {
+	buf := new(strings.Builder)
+	buf.WriteString("{\n")
+	g.writeFields(buf, typ, indent+"  ")
+	buf.WriteString(indent + "}")
+	return buf.String()
+}
+
+// writeFields writes the fields of typ, the embedded structs without json name are flattened like encoding/json
+func (g *tsGenerator) writeFields(buf *strings.Builder, typ reflect.Type, indent string) {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if field.Anonymous && len(name) == 0 && IndirectType(field.Type).Kind() == reflect.Struct {
+			g.writeFields(buf, IndirectType(field.Type), indent)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if len(name) == 0 {
+			name = field.Name
+		}
+		if !tsIdentifier(name) {
+			name = fmt.Sprintf("%q", name)
+		}
+		optional := ""
+		if field.Type.Kind() == reflect.Ptr || util.Contains(strings.Split(opts, ","), "omitempty") {
+			optional = "?"
+		}
+		fieldType := g.typeOf(field.Type)
+		if util.Contains(strings.Split(opts, ","), "string") {
+			fieldType = "string"
+		}
+		if IndirectType(field.Type).Kind() == reflect.Struct && len(IndirectType(field.Type).Name()) == 0 {
+			fieldType = g.structBody(IndirectType(field.Type), indent)
+		}
+		fmt.Fprintf(buf, "%s%s%s: %s;\n", indent, name, optional, fieldType)
+	}
+}
+
+// tsTypeName the name of the struct, the type arguments of a generic type are kept as letters
+func tsTypeName(typ reflect.Type, prefix ...string) string {
+	name := typ.Name()
+	if len(prefix) > 0 {
+		name = tsExported(prefix[0]) + name
+	}
+	return tsSanitize(name)
+}
+
+// tsSanitize removes the characters which are invalid in an identifier
+func tsSanitize(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' {
+			return r
+		}
+		return -1
+	}, name)
+}
+
+// tsClientName user.admin -> UserAdminClient
+func tsClientName(namespace string) string {
+	if len(namespace) == 0 {
+		return "RootClient"
+	}
+	var name string
+	for _, elem := range strings.Split(namespace, Separator) {
+		name += tsExported(elem)
+	}
+	return tsSanitize(name) + "Client"
+}
+
+// tsFieldName user.admin -> userAdmin
+func tsFieldName(namespace string) string {
+	name := strings.TrimSuffix(tsClientName(namespace), "Client")
+	return strings.ToLower(name[:1]) + name[1:]
+}
+
+// tsExported ...
+func tsExported(name string) string {
+	if len(name) == 0 {
+		return name
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+// tsIdentifier ...
+func tsIdentifier(name string) bool {
+	if len(name) == 0 {
+		return false
+	}
+	for i, r := range name {
+		if !(unicode.IsLetter(r) || r == '_' || r == '$' || (i > 0 && unicode.IsDigit(r))) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,103 @@
+package j2rpc
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type (
+	tsService struct{}
+
+	tsBase struct {
+		ID int64 `json:"id"`
+	}
+
+	tsUser struct {
+		tsBase
+		Name     string            `json:"name"`
+		Email    *string           `json:"email"`
+		Nick     string            `json:"nick,omitempty"`
+		Count    int64             `json:"count,string"`
+		Created  time.Time         `json:"created_at"`
+		Labels   map[string]string `json:"labels"`
+		Friends  []*tsUser         `json:"friends"`
+		Avatar   []byte            `json:"avatar"`
+		Internal string            `json:"-"`
+		Address  struct {
+			City string `json:"city"`
+		} `json:"address"`
+		secret string
+	}
+
+	tsQuery struct {
+		Keyword string `json:"keyword"`
+	}
+)
+
+func (s *tsService) Get(_ context.Context, id int64) (*tsUser, error) { return nil, nil }
+
+func (s *tsService) Find(_ context.Context, query tsQuery, page *int) ([]*tsUser, error) {
+	return nil, nil
+}
+
+func (s *tsService) Touch(_ context.Context) error { return nil }
+
+func (s *tsService) RPCParamNames(method string) []string {
+	if method == "Find" {
+		return []string{"query", "page"}
+	}
+	return nil
+}
+
+func TestWriteTypeScript(t *testing.T) {
+	s := NewServer()
+	s.RegisterType(new(tsService), "user.admin")
+	Handle(s, "calc.add", func(_ Context, req tsQuery) (int, error) { return 0, nil })
+	HandleStream(s, "calc.ticks", func(_ Context, req tsQuery, send func(item tsBase) error) error { return nil })
+	data, err := GenerateTypeScript(s)
+	require.NoError(t, err)
+	ts := string(data)
+
+	assert.True(t, strings.HasPrefix(ts, "// Code generated by j2rpc.WriteTypeScript. DO NOT EDIT."))
+	for _, want := range []string{
+		"export interface tsUser {\n" +
+			"  id: number;\n" +
+			"  name: string;\n" +
+			"  email?: string;\n" +
+			"  nick?: string;\n" +
+			"  count: string;\n" +
+			"  created_at: string;\n" +
+			"  labels: Record<string, string>;\n" +
+			"  friends: tsUser[];\n" +
+			"  avatar: string;\n" +
+			"  address: {\n" +
+			"    city: string;\n" +
+			"  };\n" +
+			"}\n",
+		"export interface tsQuery {\n  keyword: string;\n}\n",
+		"export class UserAdminClient {",
+		"  async get(arg0: number): Promise<tsUser> {\n    return this.transport.call<tsUser>(\"user.admin.get\", [arg0]);\n  }\n",
+		"  async find(query: tsQuery, page?: number): Promise<tsUser[]> {",
+		"  async touch(): Promise<void> {",
+		"export class CalcClient {",
+		"  async add(arg0: tsQuery): Promise<number> {",
+		"  // ticks streams Server-Sent Events of tsBase\n",
+		"  readonly calc: CalcClient;\n  readonly userAdmin: UserAdminClient;\n",
+		"    this.userAdmin = new UserAdminClient(transport);\n",
+	} {
+		assert.Contains(t, ts, want)
+	}
+	assert.NotContains(t, ts, "Internal")
+	assert.NotContains(t, ts, "secret")
+	assert.Equal(t, 1, strings.Count(ts, "export interface tsUser "))
+
+	// the output is stable
+	again, err := GenerateTypeScript(s)
+	require.NoError(t, err)
+	assert.Equal(t, ts, string(again))
+}
@@ -0,0 +1,69 @@
+package j2rpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	v1 "github.com/atcharles/glibs/j2rpc"
+)
+
+type (
+	validateService struct{}
+
+	validateAddr struct {
+		City string `json:"city" validate:"required"`
+	}
+
+	validateReq struct {
+		UserName string       `json:"user_name" validate:"required"`
+		Addr     validateAddr `json:"addr"`
+	}
+)
+
+func (validateService) J2rpcSkipValidate() []string { return []string{"Loose"} }
+
+func (validateService) Strict(_ context.Context, req validateReq) (string, error) { return "ok", nil }
+
+func (validateService) Loose(_ context.Context, req validateReq) (string, error) { return "ok", nil }
+
+func TestValidateBothServers(t *testing.T) {
+	s2 := NewServer()
+	s2.RegisterType(validateService{}, "svc")
+	s1 := v1.New(&v1.Option{})
+	s1.Register(validateService{}, "svc")
+	methods := map[http.Handler][2]string{
+		s2: {"svc.strict", "svc.loose"},
+		s1: {"svc.Strict", "svc.Loose"},
+	}
+	for handler, names := range methods {
+		call := func(method string) (resp struct {
+			Result string `json:"result"`
+			Error  *Error `json:"error"`
+		}) {
+			body := `{"jsonrpc":"2.0","id":1,"method":"` + method + `","params":[{"addr":{}}]}`
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp), w.Body.String())
+			return
+		}
+
+		strict := call(names[0])
+		require.NotNil(t, strict.Error, names[0])
+		assert.Equal(t, ErrBadParams, strict.Error.Code)
+		data, ok := strict.Error.Data.(map[string]interface{})
+		require.True(t, ok, names[0])
+		assert.Contains(t, data, "user_name")
+		assert.Contains(t, data, "addr.city")
+
+		loose := call(names[1])
+		assert.Nil(t, loose.Error, names[1])
+		assert.Equal(t, "ok", loose.Result)
+	}
+}
@@ -96,7 +96,7 @@ func (c *CurdParams) Delete() (err error) {
 	if err = c.Values.ToBean(bean); err != nil {
 		return
 	}
-	if err = db.Where("id = ?", idv).Take(bean).Error; err != nil {
+	if err = Primary(db.DB).Where("id = ?", idv).Take(bean).Error; err != nil {
 		return j2rpc.NewError(400, err.Error())
 	}
 	if c.BeforeCall != nil {
@@ -132,8 +132,8 @@ func (c *CurdParams) Update() (err error) {
 		}
 	}
 	oldVal := util.NewValue(c.Model)
-	// get from db
-	if err = db.Where("id = ?", idUint64).Take(oldVal).Error; err != nil {
+	// get from the primary, the replicas may lag behind
+	if err = Primary(db.DB).Where("id = ?", idUint64).Take(oldVal).Error; err != nil {
 		return j2rpc.NewError(400, err.Error())
 	}
 	newVal := util.NewValue(c.Model)
@@ -1,18 +1,23 @@
 package mdb
 
 import (
+	"context"
 	"crypto/md5"
+	"database/sql"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"log"
+	"os"
+	"path/filepath"
 	"reflect"
 	"regexp"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/glebarez/sqlite"
 	"github.com/spf13/cast"
 	"gorm.io/driver/mysql"
 	"gorm.io/driver/postgres"
@@ -33,12 +38,14 @@ var (
 )
 
 type DBOption struct {
+	//Type mysql, pg(postgres) or sqlite
 	Type string `json:"type"`
 	Host string `json:"host"`
 	Port string `json:"port"`
 	User string `json:"user"`
 	Pwd  string `json:"pwd"`
-	DB   string `json:"db"`
+	//DB the database name, the file of sqlite which is relative to data under the root dir, or :memory:
+	DB string `json:"db"`
 
 	SkipCache bool   `json:"skip_cache"`
 	CacheType string `json:"cache_type"`
@@ -51,6 +58,16 @@ type DBOption struct {
 	MaxIdleTimeSeconds int64 `json:"max_idle_time_seconds"`
 
 	SkipCreateDB bool `json:"skipCreateDb,omitempty"`
+
+	//Replicas the read replicas, the SELECTs out of the transactions and the cache misses are sent to them, see Primary
+	Replicas []ReplicaOption `json:"replicas,omitempty"`
+	//ReplicaPolicy round_robin(default) or weighted
+	ReplicaPolicy string `json:"replica_policy,omitempty"`
+	//ReplicaCheckSeconds the interval of the health checks of the replicas, default 10
+	ReplicaCheckSeconds int64 `json:"replica_check_seconds,omitempty"`
+	//CacheFromPrimary the cache misses are read from the primary, so a lagging replica never fills the cache
+	//with the rows before the last write
+	CacheFromPrimary bool `json:"cache_from_primary,omitempty"`
 }
 
 // DSN ...
@@ -68,38 +85,7 @@ func (d *DBOption) GetLogger() logger.Interface {
 }
 
 func (d *DBOption) dbInitiate() (db *gorm.DB, err error) {
-	dsn := d.parseDSN()
-	var dialectal gorm.Dialector
-	switch d.Type {
-	case "mysql":
-		dialectal = mysql.New(mysql.Config{
-			DriverName:                    "mysql",
-			ServerVersion:                 "",
-			DSN:                           dsn,
-			DSNConfig:                     nil,
-			Conn:                          nil,
-			SkipInitializeWithVersion:     false,
-			DefaultStringSize:             256,
-			DefaultDatetimePrecision:      &defaultDatetimePrecision,
-			DisableWithReturning:          false,
-			DisableDatetimePrecision:      true,
-			DontSupportRenameIndex:        true,
-			DontSupportRenameColumn:       false,
-			DontSupportForShareClause:     false,
-			DontSupportNullAsDefaultValue: false,
-			DontSupportRenameColumnUnique: false,
-		})
-	case "pg", "postgres":
-		dialectal = postgres.New(postgres.Config{
-			DriverName:           "pgx",
-			DSN:                  dsn,
-			PreferSimpleProtocol: false,
-			WithoutReturning:     false,
-			Conn:                 nil,
-		})
-	default:
-		panic("unknown db type")
-	}
+	dialectal := d.dialector()
 	gormConfig := &gorm.Config{
 		SkipDefaultTransaction:                   false,
 		NamingStrategy:                           nil,
@@ -129,47 +115,7 @@ func (d *DBOption) dbInitiate() (db *gorm.DB, err error) {
 	if err != nil {
 		return
 	}
-	// SetMaxOpenConns 设置打开数据库连接的最大数量
-	if d.MaxOpenConns <= 0 {
-		d.MaxOpenConns = 200
-	}
-	if d.MaxOpenConns >= 2000 {
-		d.MaxOpenConns = 2000
-	}
-	//sqlDB.SetMaxOpenConns(200)
-	sqlDB.SetMaxOpenConns(d.MaxOpenConns)
-
-	if d.MaxIdleConns <= 0 {
-		d.MaxIdleConns = 50
-	}
-	if d.MaxIdleConns >= d.MaxOpenConns {
-		d.MaxIdleConns = d.MaxOpenConns / 2
-	}
-	// SetMaxIdleConns 设置空闲连接池中连接的最大数量
-	//sqlDB.SetMaxIdleConns(50)
-	sqlDB.SetMaxIdleConns(d.MaxIdleConns)
-
-	if d.MaxLifetimeSeconds <= 0 {
-		d.MaxLifetimeSeconds = 30
-	}
-	if d.MaxLifetimeSeconds >= 3600 {
-		d.MaxLifetimeSeconds = 3600
-	}
-	// SetConnMaxLifetime 设置了连接可复用的最大时间
-	//sqlDB.SetConnMaxLifetime(time.Hour * 10)
-	sqlDB.SetConnMaxLifetime(time.Duration(d.MaxLifetimeSeconds) * time.Second)
-	//sqlDB.SetConnMaxLifetime(time.Second * 59)
-
-	if d.MaxIdleTimeSeconds <= 0 {
-		d.MaxIdleTimeSeconds = 30
-	}
-	if d.MaxIdleTimeSeconds >= d.MaxLifetimeSeconds {
-		d.MaxIdleTimeSeconds = d.MaxLifetimeSeconds * 80 / 100
-	}
-	// SetConnMaxIdleTime 设置空闲连接池中连接的最大空闲时间
-	//sqlDB.SetConnMaxIdleTime(time.Hour * 11)
-	sqlDB.SetConnMaxIdleTime(time.Duration(d.MaxIdleTimeSeconds) * time.Second)
-	//sqlDB.SetConnMaxIdleTime(time.Second * 60)
+	d.setConnPool(sqlDB)
 	if err = sqlDB.Ping(); err != nil {
 		return
 	}
@@ -180,12 +126,19 @@ func (d *DBOption) dbInitiate() (db *gorm.DB, err error) {
 	//})
 	//d.GetLogger().Info(context.Background(), "db connected")
 
+	if len(d.Replicas) > 0 {
+		if err = db.Use(&gormPluginReplica{opt: d, done: make(chan struct{})}); err != nil {
+			return
+		}
+	}
+
 	if !d.SkipCache {
 		gm2opt := Config{
-			Skip:   d.SkipCache,
-			TTL:    60 * 10,
-			Prefix: d.DSNMd5(),
-			Store:  d.getCacheStore(),
+			Skip:        d.SkipCache,
+			TTL:         60 * 10,
+			Prefix:      d.DSNMd5(),
+			Store:       d.getCacheStore(),
+			FromPrimary: d.CacheFromPrimary,
 		}
 		if err = db.Use(NewPlugin(gm2opt)); err != nil {
 			return
@@ -196,6 +149,44 @@ func (d *DBOption) dbInitiate() (db *gorm.DB, err error) {
 	return
 }
 
+// dialector ...
+func (d *DBOption) dialector() (dialectal gorm.Dialector) {
+	dsn := d.parseDSN()
+	switch d.Type {
+	case "mysql":
+		dialectal = mysql.New(mysql.Config{
+			DriverName:                    "mysql",
+			ServerVersion:                 "",
+			DSN:                           dsn,
+			DSNConfig:                     nil,
+			Conn:                          nil,
+			SkipInitializeWithVersion:     false,
+			DefaultStringSize:             256,
+			DefaultDatetimePrecision:      &defaultDatetimePrecision,
+			DisableWithReturning:          false,
+			DisableDatetimePrecision:      true,
+			DontSupportRenameIndex:        true,
+			DontSupportRenameColumn:       false,
+			DontSupportForShareClause:     false,
+			DontSupportNullAsDefaultValue: false,
+			DontSupportRenameColumnUnique: false,
+		})
+	case "pg", "postgres":
+		dialectal = postgres.New(postgres.Config{
+			DriverName:           "pgx",
+			DSN:                  dsn,
+			PreferSimpleProtocol: false,
+			WithoutReturning:     false,
+			Conn:                 nil,
+		})
+	case "sqlite":
+		dialectal = sqlite.Open(dsn)
+	default:
+		panic("unknown db type")
+	}
+	return
+}
+
 // getCacheStore ...
 func (d *DBOption) getCacheStore() CacheStore {
 	switch d.CacheType {
@@ -225,11 +216,79 @@ sslmode=disable
 TimeZone=Asia/Shanghai`
 		dsn = util.TextTemplateMustParse(dsn, d)
 		return dsn
+	case "sqlite":
+		if d.sqliteMemory() {
+			return ":memory:"
+		}
+		return d.sqliteFile() + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)"
 	default:
 		panic("unknown db type")
 	}
 }
 
+// setConnPool ...
+func (d *DBOption) setConnPool(sqlDB *sql.DB) {
+	// SetMaxOpenConns 设置打开数据库连接的最大数量
+	if d.MaxOpenConns <= 0 {
+		d.MaxOpenConns = 200
+	}
+	if d.MaxOpenConns >= 2000 {
+		d.MaxOpenConns = 2000
+	}
+	//sqlDB.SetMaxOpenConns(200)
+	sqlDB.SetMaxOpenConns(d.MaxOpenConns)
+
+	if d.MaxIdleConns <= 0 {
+		d.MaxIdleConns = 50
+	}
+	if d.MaxIdleConns >= d.MaxOpenConns {
+		d.MaxIdleConns = d.MaxOpenConns / 2
+	}
+	// SetMaxIdleConns 设置空闲连接池中连接的最大数量
+	//sqlDB.SetMaxIdleConns(50)
+	sqlDB.SetMaxIdleConns(d.MaxIdleConns)
+
+	if d.MaxLifetimeSeconds <= 0 {
+		d.MaxLifetimeSeconds = 30
+	}
+	if d.MaxLifetimeSeconds >= 3600 {
+		d.MaxLifetimeSeconds = 3600
+	}
+	// SetConnMaxLifetime 设置了连接可复用的最大时间
+	//sqlDB.SetConnMaxLifetime(time.Hour * 10)
+	sqlDB.SetConnMaxLifetime(time.Duration(d.MaxLifetimeSeconds) * time.Second)
+	//sqlDB.SetConnMaxLifetime(time.Second * 59)
+
+	if d.MaxIdleTimeSeconds <= 0 {
+		d.MaxIdleTimeSeconds = 30
+	}
+	if d.MaxIdleTimeSeconds >= d.MaxLifetimeSeconds {
+		d.MaxIdleTimeSeconds = d.MaxLifetimeSeconds * 80 / 100
+	}
+	// SetConnMaxIdleTime 设置空闲连接池中连接的最大空闲时间
+	//sqlDB.SetConnMaxIdleTime(time.Hour * 11)
+	sqlDB.SetConnMaxIdleTime(time.Duration(d.MaxIdleTimeSeconds) * time.Second)
+	//sqlDB.SetConnMaxIdleTime(time.Second * 60)
+	if d.sqliteMemory() {
+		// 每个连接是独立的内存数据库, 只保留一个永不关闭的连接
+		sqlDB.SetMaxOpenConns(1)
+		sqlDB.SetMaxIdleConns(1)
+		sqlDB.SetConnMaxLifetime(0)
+		sqlDB.SetConnMaxIdleTime(0)
+	}
+}
+
+// sqliteFile the database file of sqlite
+func (d *DBOption) sqliteFile() string {
+	if filepath.IsAbs(d.DB) {
+		return d.DB
+	}
+	return filepath.Join(util.RootDir(), "data", d.DB)
+}
+
+// sqliteMemory ...
+func (d *DBOption) sqliteMemory() bool { return d.Type == "sqlite" && d.DB == ":memory:" }
+
 type GormDB struct {
 	*gorm.DB
 	opt *DBOption
@@ -252,6 +311,9 @@ func (g *GormDB) Close() (err error) {
 	if err = g.CheckDBNil(); err != nil {
 		return
 	}
+	if p, ok := g.Config.Plugins["plugin-replica"].(*gormPluginReplica); ok {
+		_ = p.Close()
+	}
 	sqlDB, err := g.DB.DB()
 	if err != nil {
 		return
@@ -347,13 +409,15 @@ func (g *GormDB) MigrateModels(v ...interface{}) {
 		models = append(models, model)
 	}
 
-	e := g.AutoMigrate(models...)
+	// the migrator reads the schema, it must be the one of the primary
+	db := g.WithContext(WithPrimary(context.Background()))
+	e := db.AutoMigrate(models...)
 	if e != nil {
 		log.Fatalf("GormDB AutoMigrate models error: %s", e.Error())
 	}
 	for _, model := range models {
 		if m, ok := model.(ItfModelInitializer); ok {
-			_e := g.initializeModel(m)
+			_e := g.initializeModel(db, m)
 			if _e != nil {
 				log.Fatalf("ItfModelInitializer data error: %s", _e.Error())
 			}
@@ -422,6 +486,13 @@ func (g *GormDB) ViewModels() map[string]interface{} { return g.viewModels }
 
 // createDB create database
 func (g *GormDB) createDB() (err error) {
+	if g.opt.Type == "sqlite" {
+		// the file is created when it's opened
+		if g.opt.sqliteMemory() {
+			return
+		}
+		return os.MkdirAll(filepath.Dir(g.opt.sqliteFile()), 0755)
+	}
 	db, err := g.openDefaultDB()
 	// create database
 	if err != nil {
@@ -453,6 +524,17 @@ func (g *GormDB) createDB() (err error) {
 }
 
 func (g *GormDB) dropDB() (err error) {
+	if g.opt.Type == "sqlite" {
+		if g.opt.sqliteMemory() {
+			return
+		}
+		for _, suffix := range []string{"", "-wal", "-shm"} {
+			if err = os.Remove(g.opt.sqliteFile() + suffix); err != nil && !os.IsNotExist(err) {
+				return
+			}
+		}
+		return nil
+	}
 	db, err := g.openDefaultDB()
 	// create database
 	if err != nil {
@@ -468,9 +550,9 @@ func (g *GormDB) dropDB() (err error) {
 }
 
 // initializeModel ...
-func (g *GormDB) initializeModel(model ItfModelInitializer) (err error) {
+func (g *GormDB) initializeModel(db *gorm.DB, model ItfModelInitializer) (err error) {
 	tbName := g.ModelTableName(model)
-	beans, err := model.InitData(g.DB)
+	beans, err := model.InitData(db)
 	if err != nil {
 		return
 	}
@@ -478,7 +560,7 @@ func (g *GormDB) initializeModel(model ItfModelInitializer) (err error) {
 		return
 	}
 	var count int64
-	if err = g.Table(tbName).Count(&count).Error; err != nil {
+	if err = db.Table(tbName).Count(&count).Error; err != nil {
 		return
 	}
 	if count > 0 {
@@ -494,7 +576,7 @@ func (g *GormDB) initializeModel(model ItfModelInitializer) (err error) {
 	//		return
 	//	}
 	//}
-	if err = g.Table(tbName).CreateInBatches(beans, 100).Error; err != nil {
+	if err = db.Table(tbName).CreateInBatches(beans, 100).Error; err != nil {
 		return
 	}
 	return
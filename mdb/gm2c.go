@@ -49,6 +49,8 @@ type Config struct {
 	TTL    int64
 	Prefix string
 	Store  CacheStore
+	// FromPrimary the cache misses are read from the primary instead of a replica, see DBOption.CacheFromPrimary
+	FromPrimary bool
 }
 
 type PluginCacheStat struct {
@@ -308,6 +310,9 @@ func (p *gormPluginCache) queryWithCache(db *gorm.DB) {
 		db.RowsAffected = 1
 		return
 	}
+	if p.FromPrimary {
+		switchPrimary(db)
+	}
 	callbacks.Query(db)
 	p.setCache(stm)
 }
@@ -0,0 +1,268 @@
+package mdb
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// ReplicaRoundRobin the replicas are used in turn
+	ReplicaRoundRobin = "round_robin"
+	// ReplicaWeighted the replicas are used in proportion to their weights
+	ReplicaWeighted = "weighted"
+
+	primaryKey = "plugin-replica:primary"
+	//primaryPoolKey the instance setting of the primary pool of the statement sent to a replica
+	primaryPoolKey = "plugin-replica:primaryPool"
+)
+
+type (
+	// ReplicaOption a read replica, the empty fields are the ones of the primary
+	ReplicaOption struct {
+		Host string `json:"host"`
+		Port string `json:"port"`
+		User string `json:"user"`
+		Pwd  string `json:"pwd"`
+		DB   string `json:"db"`
+		//Weight of the weighted policy, default is 1
+		Weight int `json:"weight"`
+	}
+
+	replica struct {
+		name    string
+		pool    *sql.DB
+		weight  int
+		current int
+		healthy atomic.Bool
+	}
+
+	gormPluginReplica struct {
+		opt      *DBOption
+		replicas []*replica
+		counter  atomic.Uint64
+		mu       sync.Mutex
+		done     chan struct{}
+		once     sync.Once
+	}
+
+	primaryContextKey struct{}
+)
+
+// lockingSQL the locking clause at the end of a SELECT, e.g. FOR UPDATE, FOR SHARE or LOCK IN SHARE MODE
+var lockingSQL = regexp.MustCompile(`(?is)\b(for\s+(update|share|no\s+key\s+update|key\s+share)|lock\s+in\s+share\s+mode)\b[^)]*$`)
+
+// Close stops the health checks and closes the replicas
+func (p *gormPluginReplica) Close() (err error) {
+	p.once.Do(func() { close(p.done) })
+	for _, r := range p.replicas {
+		if e := r.pool.Close(); e != nil {
+			err = e
+		}
+	}
+	return
+}
+
+// Initialize opens the replicas, the SELECTs out of the transactions are sent to them
+func (p *gormPluginReplica) Initialize(db *gorm.DB) (err error) {
+	for _, ro := range p.opt.Replicas {
+		opt := p.opt.replica(ro)
+		rdb, e := gorm.Open(opt.dialector(), &gorm.Config{Logger: p.opt.GetLogger(), DisableAutomaticPing: true})
+		if e != nil {
+			p.closeReplicas()
+			return e
+		}
+		sqlDB, e := rdb.DB()
+		if e != nil {
+			p.closeReplicas()
+			return e
+		}
+		opt.setConnPool(sqlDB)
+		r := &replica{name: opt.DB, pool: sqlDB, weight: ro.Weight}
+		if len(opt.Host) > 0 {
+			r.name = opt.Host + ":" + opt.Port + "/" + opt.DB
+		}
+		if r.weight <= 0 {
+			r.weight = 1
+		}
+		p.replicas = append(p.replicas, r)
+	}
+	p.check()
+	go p.checkLoop()
+
+	cb := db.Callback()
+	_ = cb.Query().Before("*").Register("plugin-replica:query", p.switchRead)
+	_ = cb.Row().Before("*").Register("plugin-replica:row", p.switchRead)
+	_ = cb.Raw().Before("*").Register("plugin-replica:raw", p.switchRaw)
+	// a reused statement of a chain may run a write next
+	_ = cb.Query().After("*").Register("plugin-replica:query_after", switchPrimary)
+	_ = cb.Row().After("*").Register("plugin-replica:row_after", switchPrimary)
+	_ = cb.Raw().After("*").Register("plugin-replica:raw_after", switchPrimary)
+	return
+}
+
+func (p *gormPluginReplica) Name() string { return "plugin-replica" }
+
+// check pings the replicas
+func (p *gormPluginReplica) check() {
+	for _, r := range p.replicas {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		err := r.pool.PingContext(ctx)
+		cancel()
+		if healthy := err == nil; r.healthy.Swap(healthy) != healthy {
+			if healthy {
+				log.Printf("db replica %s is up\n", r.name)
+			} else {
+				log.Printf("db replica %s is down: %s\n", r.name, err.Error())
+			}
+		}
+	}
+}
+
+func (p *gormPluginReplica) checkLoop() {
+	seconds := p.opt.ReplicaCheckSeconds
+	if seconds <= 0 {
+		seconds = 10
+	}
+	ticker := time.NewTicker(time.Duration(seconds) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+			p.check()
+		}
+	}
+}
+
+func (p *gormPluginReplica) closeReplicas() {
+	for _, r := range p.replicas {
+		_ = r.pool.Close()
+	}
+	p.replicas = nil
+}
+
+// pick a healthy replica by the policy, nil if there is none
+func (p *gormPluginReplica) pick() *replica {
+	healthy := make([]*replica, 0, len(p.replicas))
+	for _, r := range p.replicas {
+		if r.healthy.Load() {
+			healthy = append(healthy, r)
+		}
+	}
+	switch {
+	case len(healthy) == 0:
+		return nil
+	case len(healthy) == 1:
+		return healthy[0]
+	case p.opt.ReplicaPolicy == ReplicaWeighted:
+		// smooth weighted round-robin
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		var best *replica
+		total := 0
+		for _, r := range healthy {
+			r.current += r.weight
+			total += r.weight
+			if best == nil || r.current > best.current {
+				best = r
+			}
+		}
+		best.current -= total
+		return best
+	default:
+		return healthy[p.counter.Add(1)%uint64(len(healthy))]
+	}
+}
+
+// readable the statement can be sent to a replica, the raw SQL must be a SELECT without locking
+func (p *gormPluginReplica) readable(db *gorm.DB) bool {
+	stm := db.Statement
+	if stm.SQL.Len() > 0 && !readSQL(stm.SQL.String()) {
+		return false
+	}
+	if _, ok := stm.ConnPool.(gorm.TxCommitter); ok {
+		return false
+	}
+	if _, ok := stm.Settings.Load(primaryKey); ok {
+		return false
+	}
+	if stm.Context != nil && stm.Context.Value(primaryContextKey{}) != nil {
+		return false
+	}
+	_, locking := stm.Clauses["FOR"]
+	return !locking
+}
+
+// switchRaw the Exec of a raw SELECT is sent to a replica as well
+func (p *gormPluginReplica) switchRaw(db *gorm.DB) {
+	if db.Statement.SQL.Len() == 0 {
+		return
+	}
+	p.switchRead(db)
+}
+
+func (p *gormPluginReplica) switchRead(db *gorm.DB) {
+	if !p.readable(db) {
+		return
+	}
+	if r := p.pick(); r != nil {
+		db.InstanceSet(primaryPoolKey, db.Statement.ConnPool)
+		db.Statement.ConnPool = r.pool
+	}
+}
+
+// readSQL the SQL is a SELECT without a locking clause, e.g. UPDATE ... RETURNING and SELECT ... FOR SHARE are writes
+func readSQL(sq string) bool {
+	sq = strings.TrimSpace(sq)
+	if len(sq) < 6 || !strings.EqualFold(sq[:6], "select") {
+		return false
+	}
+	return !lockingSQL.MatchString(sq)
+}
+
+// switchPrimary sends the statement back to the primary if it was sent to a replica, after the read,
+// and before the cache fills when DBOption.CacheFromPrimary
+func switchPrimary(db *gorm.DB) {
+	if pool, ok := db.InstanceGet(primaryPoolKey); ok {
+		db.Statement.ConnPool = pool.(gorm.ConnPool)
+	}
+}
+
+// replica the option of the replica, the empty fields are the ones of d
+func (d *DBOption) replica(r ReplicaOption) *DBOption {
+	opt := *d
+	opt.Replicas = nil
+	if len(r.Host) > 0 {
+		opt.Host = r.Host
+	}
+	if len(r.Port) > 0 {
+		opt.Port = r.Port
+	}
+	if len(r.User) > 0 {
+		opt.User = r.User
+	}
+	if len(r.Pwd) > 0 {
+		opt.Pwd = r.Pwd
+	}
+	if len(r.DB) > 0 {
+		opt.DB = r.DB
+	}
+	return &opt
+}
+
+// Primary the queries of the returned db are sent to the primary, e.g. to read what was just written
+func Primary(db *gorm.DB) *gorm.DB { return db.Set(primaryKey, true) }
+
+// WithPrimary the queries with the returned context are sent to the primary, see Primary
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryContextKey{}, true)
+}
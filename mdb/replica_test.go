@@ -0,0 +1,188 @@
+package mdb
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type replicaKV struct {
+	ID uint64 `gorm:"primaryKey"`
+	V  string
+}
+
+func (replicaKV) TableName() string { return "kv" }
+
+// newReplicaDB a sqlite primary and a replica, the row 1 of kv is "primary" in the former and "replica" in the latter,
+// configure changes the option of the primary
+func newReplicaDB(t *testing.T, configure func(opt *DBOption)) (db *gorm.DB, replica *gorm.DB) {
+	t.Helper()
+	dir := t.TempDir()
+	replicaFile := filepath.Join(dir, "replica.db")
+	ro := &DBOption{Type: "sqlite", DB: replicaFile, SkipCache: true, Logger: NewDBLoggerSilent()}
+	replica, err := ro.dbInitiate()
+	require.NoError(t, err)
+	opt := &DBOption{
+		Type:      "sqlite",
+		DB:        filepath.Join(dir, "primary.db"),
+		SkipCache: true,
+		Logger:    NewDBLoggerSilent(),
+		Replicas:  []ReplicaOption{{DB: replicaFile}},
+	}
+	if configure != nil {
+		configure(opt)
+	}
+	db, err = opt.dbInitiate()
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = (&GormDB{DB: db}).Close()
+		_ = (&GormDB{DB: replica}).Close()
+	})
+	for v, d := range map[string]*gorm.DB{"primary": Primary(db), "replica": replica} {
+		require.NoError(t, d.Exec("CREATE TABLE kv (id INTEGER PRIMARY KEY, v TEXT)").Error)
+		require.NoError(t, d.Exec("INSERT INTO kv (id, v) VALUES (1, ?)", v).Error)
+	}
+	return
+}
+
+func TestReplicaRouting(t *testing.T) {
+	db, replica := newReplicaDB(t, nil)
+	tests := []struct {
+		name string
+		read func() (string, error)
+		want string
+	}{
+		{"query", func() (v string, err error) {
+			var row replicaKV
+			err = db.First(&row, 1).Error
+			return row.V, err
+		}, "replica"},
+		{"raw select", func() (v string, err error) {
+			err = db.Raw("SELECT v FROM kv WHERE id = 1").Scan(&v).Error
+			return
+		}, "replica"},
+		{"row", func() (v string, err error) {
+			err = db.Model(&replicaKV{}).Select("v").Where("id = 1").Row().Scan(&v)
+			return
+		}, "replica"},
+		{"primary", func() (v string, err error) {
+			err = Primary(db).Raw("SELECT v FROM kv WHERE id = 1").Scan(&v).Error
+			return
+		}, "primary"},
+		{"primary context", func() (v string, err error) {
+			err = db.WithContext(WithPrimary(context.Background())).Raw("SELECT v FROM kv WHERE id = 1").Scan(&v).Error
+			return
+		}, "primary"},
+		{"transaction", func() (v string, err error) {
+			err = db.Transaction(func(tx *gorm.DB) error {
+				return tx.Raw("SELECT v FROM kv WHERE id = 1").Scan(&v).Error
+			})
+			return
+		}, "primary"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := tt.read()
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, v)
+		})
+	}
+
+	// the raw writes must not be sent to the replica
+	writes := []struct {
+		name  string
+		write func(v string) (string, error)
+	}{
+		{"scan", func(v string) (out string, err error) {
+			err = db.Raw("UPDATE kv SET v = ? WHERE id = 1 RETURNING v", v).Scan(&out).Error
+			return
+		}},
+		{"row", func(v string) (out string, err error) {
+			err = db.Raw("UPDATE kv SET v = ? WHERE id = 1 RETURNING v", v).Row().Scan(&out)
+			return
+		}},
+		{"rows", func(v string) (out string, err error) {
+			rows, err := db.Raw("UPDATE kv SET v = ? WHERE id = 1 RETURNING v", v).Rows()
+			if err != nil {
+				return
+			}
+			defer rows.Close()
+			for rows.Next() {
+				err = rows.Scan(&out)
+			}
+			return
+		}},
+	}
+	// a reused chain runs the write on the primary after a read on the replica
+	writes = append(writes, struct {
+		name  string
+		write func(v string) (string, error)
+	}{"chain", func(v string) (string, error) {
+		var row replicaKV
+		tx := db.Table("kv").Where("id = ?", 1)
+		if err := tx.Take(&row).Error; err != nil || row.V != "replica" {
+			return row.V, err
+		}
+		return v, tx.Exec("UPDATE kv SET v = ? WHERE id = 1", v).Error
+	}})
+	for _, tt := range writes {
+		t.Run("raw write "+tt.name, func(t *testing.T) {
+			out, err := tt.write(tt.name)
+			require.NoError(t, err)
+			assert.Equal(t, tt.name, out)
+			var v string
+			require.NoError(t, Primary(db).Raw("SELECT v FROM kv WHERE id = 1").Scan(&v).Error)
+			assert.Equal(t, tt.name, v)
+			require.NoError(t, replica.Raw("SELECT v FROM kv WHERE id = 1").Scan(&v).Error)
+			assert.Equal(t, "replica", v)
+		})
+	}
+}
+
+func TestReplicaCacheFill(t *testing.T) {
+	for fromPrimary, want := range map[bool]string{false: "replica", true: "primary"} {
+		db, _ := newReplicaDB(t, func(opt *DBOption) {
+			opt.SkipCache = false
+			opt.CacheFromPrimary = fromPrimary
+		})
+		for i := 0; i < 2; i++ {
+			var row replicaKV
+			require.NoError(t, db.First(&row, 1).Error)
+			assert.Equal(t, want, row.V, "from primary %v", fromPrimary)
+		}
+		// the next write is sent to the primary
+		require.NoError(t, db.Model(&replicaKV{ID: 1}).Update("v", "written").Error)
+		var v string
+		require.NoError(t, Primary(db).Raw("SELECT v FROM kv WHERE id = 1").Scan(&v).Error)
+		assert.Equal(t, "written", v)
+	}
+}
+
+func TestReadSQL(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want bool
+	}{
+		{"SELECT * FROM kv", true},
+		{"  select * from kv;", true},
+		{"SELECT * FROM kv FOR UPDATE", false},
+		{"select * from kv for update;", false},
+		{"SELECT * FROM kv FOR UPDATE NOWAIT", false},
+		{"SELECT * FROM kv FOR SHARE", false},
+		{"select * from kv for no key update skip locked", false},
+		{"SELECT * FROM kv LOCK IN SHARE MODE", false},
+		{"SELECT * FROM kv WHERE id IN (SELECT id FROM t FOR UPDATE) ORDER BY id", true},
+		{"SELECT * FROM kv WHERE v = 'for'", true},
+		{"UPDATE kv SET v = 1 RETURNING v", false},
+		{"INSERT INTO kv (v) VALUES (1) RETURNING id", false},
+		{"DELETE FROM kv RETURNING id", false},
+		{"sel", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, readSQL(tt.sql), tt.sql)
+	}
+}
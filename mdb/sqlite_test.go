@@ -0,0 +1,88 @@
+package mdb
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/atcharles/glibs/util"
+)
+
+func TestSqliteCreateDropDB(t *testing.T) {
+	root := t.TempDir()
+	rootDir := util.RootDir
+	util.RootDir = func() string { return root }
+	t.Cleanup(func() { util.RootDir = rootDir })
+
+	tests := []struct {
+		name string
+		db   string
+		// file the expected database file
+		file string
+	}{
+		{"relative", filepath.Join("sub", "app.db"), filepath.Join(root, "data", "sub", "app.db")},
+		{"absolute", filepath.Join(root, "abs", "app.db"), filepath.Join(root, "abs", "app.db")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := &GormDB{opt: &DBOption{Type: "sqlite", DB: tt.db, SkipCache: true, Logger: NewDBLoggerSilent()}}
+			require.Equal(t, tt.file, g.opt.sqliteFile())
+			require.NoError(t, g.createDB())
+			// creating twice is fine
+			require.NoError(t, g.createDB())
+			assert.DirExists(t, filepath.Dir(tt.file))
+
+			db, err := g.opt.dbInitiate()
+			require.NoError(t, err)
+			require.NoError(t, db.AutoMigrate(&replicaKV{}))
+			require.NoError(t, db.Create(&replicaKV{ID: 1, V: "v"}).Error)
+			require.NoError(t, (&GormDB{DB: db}).Close())
+			assert.FileExists(t, tt.file)
+
+			// the WAL files are left over when the database isn't checkpointed
+			for _, suffix := range []string{"-wal", "-shm"} {
+				require.NoError(t, os.WriteFile(tt.file+suffix, nil, 0644))
+			}
+			require.NoError(t, g.dropDB())
+			for _, suffix := range []string{"", "-wal", "-shm"} {
+				assert.NoFileExists(t, tt.file+suffix)
+			}
+			// dropping a missing database is fine
+			require.NoError(t, g.dropDB())
+		})
+	}
+}
+
+func TestSqliteMemory(t *testing.T) {
+	opt := &DBOption{Type: "sqlite", DB: ":memory:", SkipCache: true, MaxOpenConns: 10, Logger: NewDBLoggerSilent()}
+	g := &GormDB{opt: opt}
+	require.NoError(t, g.createDB())
+	require.NoError(t, g.dropDB())
+	assert.Equal(t, ":memory:", opt.DSN())
+
+	db, err := opt.dbInitiate()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = (&GormDB{DB: db}).Close() })
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	assert.Equal(t, 1, sqlDB.Stats().MaxOpenConnections)
+
+	// every connection would be a new empty database, the data must persist across the queries
+	require.NoError(t, db.AutoMigrate(&replicaKV{}))
+	for i := uint64(1); i <= 5; i++ {
+		require.NoError(t, db.Create(&replicaKV{ID: i, V: "v"}).Error)
+	}
+	var count int64
+	require.NoError(t, db.Model(&replicaKV{}).Count(&count).Error)
+	assert.EqualValues(t, 5, count)
+	require.NoError(t, db.Transaction(func(tx *gorm.DB) error {
+		return tx.Model(&replicaKV{}).Where("id = ?", 1).Update("v", "tx").Error
+	}))
+	var kv replicaKV
+	require.NoError(t, db.First(&kv, 1).Error)
+	assert.Equal(t, "tx", kv.V)
+}
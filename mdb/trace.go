@@ -0,0 +1,74 @@
+package mdb
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+
+	"github.com/atcharles/glibs/trace"
+)
+
+// traceSpanKey the setting of the statement's span
+const traceSpanKey = "plugin-trace:span"
+
+type gormPluginTrace struct {
+	tracer *trace.Tracer
+}
+
+// Initialize registers a span around each statement
+func (p *gormPluginTrace) Initialize(db *gorm.DB) (err error) {
+	cb := db.Callback()
+	_ = cb.Create().Before("*").Register("plugin-trace:beforeCreate", p.before("create"))
+	_ = cb.Create().After("*").Register("plugin-trace:afterCreate", p.after)
+	_ = cb.Query().Before("*").Register("plugin-trace:beforeQuery", p.before("query"))
+	_ = cb.Query().After("*").Register("plugin-trace:afterQuery", p.after)
+	_ = cb.Update().Before("*").Register("plugin-trace:beforeUpdate", p.before("update"))
+	_ = cb.Update().After("*").Register("plugin-trace:afterUpdate", p.after)
+	_ = cb.Delete().Before("*").Register("plugin-trace:beforeDelete", p.before("delete"))
+	_ = cb.Delete().After("*").Register("plugin-trace:afterDelete", p.after)
+	_ = cb.Row().Before("*").Register("plugin-trace:beforeRow", p.before("row"))
+	_ = cb.Row().After("*").Register("plugin-trace:afterRow", p.after)
+	_ = cb.Raw().Before("*").Register("plugin-trace:beforeRaw", p.before("raw"))
+	_ = cb.Raw().After("*").Register("plugin-trace:afterRaw", p.after)
+	return
+}
+
+func (p *gormPluginTrace) Name() string { return "plugin-trace" }
+
+func (p *gormPluginTrace) after(db *gorm.DB) {
+	val, ok := db.InstanceGet(traceSpanKey)
+	if !ok {
+		return
+	}
+	span := val.(*trace.Span)
+	stm := db.Statement
+	if len(stm.Table) > 0 {
+		span.SetAttr("db.sql.table", stm.Table)
+	}
+	span.SetAttr("db.statement", stm.SQL.String()).SetAttr("db.rows_affected", db.RowsAffected)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		span.SetError(db.Error)
+	}
+	span.Finish()
+}
+
+func (p *gormPluginTrace) before(operation string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		ctx := db.Statement.Context
+		if !trace.SpanContextFromContext(ctx).IsValid() {
+			return
+		}
+		_, span := p.tracer.Start(ctx, strings.TrimSpace("gorm "+operation+" "+db.Statement.Table), trace.KindClient)
+		span.SetAttr("db.system", db.Dialector.Name()).SetAttr("db.operation", operation)
+		db.InstanceSet(traceSpanKey, span)
+	}
+}
+
+// TracePlugin starts a span per statement, it's the child of the span of the statement's context,
+// the statements without a span aren't traced, e.g.
+//
+//	db.Use(mdb.TracePlugin(tracer))
+//	db.WithContext(ctx).First(&user)
+func TracePlugin(tracer *trace.Tracer) gorm.Plugin {
+	return &gormPluginTrace{tracer: tracer}
+}
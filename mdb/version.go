@@ -159,7 +159,8 @@ func (v *Version) UpdateClauses(field *schema.Field) []clause.Interface {
 	return []clause.Interface{UpdateClause{Field: field}}
 }
 
-func (v *Version) Value() (driver.Value, error) {
+// Value is a value receiver, the drivers which don't convert by reflection (e.g. sqlite) get the field by value
+func (v Version) Value() (driver.Value, error) {
 	if !v.Valid {
 		return nil, nil
 	}
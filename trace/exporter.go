@@ -0,0 +1,113 @@
+package trace
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/atcharles/glibs/util"
+)
+
+// Exporter receives the finished spans
+type Exporter interface {
+	Export(span *Span) error
+	Close() error
+}
+
+// Record the JSON form of a span written by WriterExporter
+type Record struct {
+	TraceID  string    `json:"trace_id"`
+	SpanID   string    `json:"span_id"`
+	ParentID string    `json:"parent_id,omitempty"`
+	Service  string    `json:"service,omitempty"`
+	Name     string    `json:"name"`
+	Kind     string    `json:"kind"`
+	Start    time.Time `json:"start"`
+	//Duration nanoseconds
+	Duration   time.Duration          `json:"duration"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+}
+
+// WriterExporter writes the spans to a writer, one JSON object per line
+type WriterExporter struct {
+	mu     sync.Mutex
+	w      io.Writer
+	enc    *json.Encoder
+	closer io.Closer
+}
+
+// Close closes the file of NewJSONLExporter
+func (e *WriterExporter) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closer == nil {
+		return nil
+	}
+	return e.closer.Close()
+}
+
+// Export ...
+func (e *WriterExporter) Export(span *Span) error {
+	r := span.record()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.enc.Encode(r)
+}
+
+// record ...
+func (s *Span) record() *Record {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := &Record{
+		TraceID:  s.Context.TraceID.String(),
+		SpanID:   s.Context.SpanID.String(),
+		Service:  s.service,
+		Name:     s.Name,
+		Kind:     s.Kind.String(),
+		Start:    s.Start,
+		Duration: s.End.Sub(s.Start),
+		Error:    s.Error,
+	}
+	if s.ParentID.IsValid() {
+		r.ParentID = s.ParentID.String()
+	}
+	if len(s.Attributes) > 0 {
+		r.Attributes = make(map[string]interface{}, len(s.Attributes))
+		for key, val := range s.Attributes {
+			r.Attributes[key] = val
+		}
+	}
+	return r
+}
+
+// NewWriterExporter ...
+func NewWriterExporter(w io.Writer) *WriterExporter {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return &WriterExporter{w: w, enc: enc}
+}
+
+// NewStdoutExporter writes the spans to the stdout
+func NewStdoutExporter() *WriterExporter { return NewWriterExporter(os.Stdout) }
+
+// NewJSONLExporter opens the file in append mode, the default file is data/trace.jsonl under the root dir
+func NewJSONLExporter(fileName ...string) (*WriterExporter, error) {
+	name := filepath.Join(util.RootDir(), "data", "trace.jsonl")
+	if len(fileName) > 0 && len(fileName[0]) > 0 {
+		name = fileName[0]
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	e := NewWriterExporter(file)
+	e.closer = file
+	return e, nil
+}
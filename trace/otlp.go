@@ -0,0 +1,368 @@
+package trace
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	// OTLPOption ...
+	OTLPOption struct {
+		//Endpoint the OTLP/HTTP traces endpoint, default is http://localhost:4318/v1/traces
+		Endpoint string
+		//Headers e.g. the authorization of the collector
+		Headers map[string]string
+		//BatchSize the spans are sent when the batch is full, default is 512
+		BatchSize int
+		//Interval the spans are sent at least every interval, default is 5s
+		Interval time.Duration
+		//Client default timeout is 10s
+		Client *http.Client
+	}
+
+	// OTLPExporter sends the spans to a collector by OTLP/HTTP JSON in batches
+	OTLPExporter struct {
+		opt    OTLPOption
+		mu     sync.Mutex
+		spans  []*Span
+		flush  chan struct{}
+		done   chan struct{}
+		closed chan struct{}
+		once   sync.Once
+	}
+
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes,omitempty"`
+	}
+
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+
+	otlpSpan struct {
+		TraceID           string         `json:"traceId"`
+		SpanID            string         `json:"spanId"`
+		ParentSpanID      string         `json:"parentSpanId,omitempty"`
+		Name              string         `json:"name"`
+		Kind              int            `json:"kind"`
+		StartTimeUnixNano string         `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+		Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+		Status            *otlpStatus    `json:"status,omitempty"`
+	}
+
+	otlpStatus struct {
+		//Code 2 is error
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	}
+
+	otlpKeyValue struct {
+		Key   string       `json:"key"`
+		Value otlpAnyValue `json:"value"`
+	}
+
+	otlpAnyValue struct {
+		StringValue *string  `json:"stringValue,omitempty"`
+		BoolValue   *bool    `json:"boolValue,omitempty"`
+		IntValue    *string  `json:"intValue,omitempty"`
+		DoubleValue *float64 `json:"doubleValue,omitempty"`
+	}
+)
+
+// otlpScopeName the instrumentation scope of the spans
+const otlpScopeName = "github.com/atcharles/glibs/trace"
+
+// Close sends the buffered spans and stops the exporter
+func (e *OTLPExporter) Close() error {
+	e.once.Do(func() { close(e.done) })
+	<-e.closed
+	return e.send()
+}
+
+// Export buffers the span, it's sent in the next batch. The span is dropped if the buffer is full.
+func (e *OTLPExporter) Export(span *Span) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.spans) >= e.opt.BatchSize*4 {
+		return fmt.Errorf("trace: otlp buffer is full, span %s dropped", span.Name)
+	}
+	e.spans = append(e.spans, span)
+	if len(e.spans) >= e.opt.BatchSize {
+		select {
+		case e.flush <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// loop ...
+func (e *OTLPExporter) loop() {
+	defer close(e.closed)
+	ticker := time.NewTicker(e.opt.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-e.done:
+			return
+		case <-ticker.C:
+		case <-e.flush:
+		}
+		_ = e.send()
+	}
+}
+
+// send posts the buffered spans
+func (e *OTLPExporter) send() error {
+	e.mu.Lock()
+	spans := e.spans
+	e.spans = nil
+	e.mu.Unlock()
+	if len(spans) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(otlpEncode(spans))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, e.opt.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, val := range e.opt.Headers {
+		req.Header.Set(key, val)
+	}
+	resp, err := e.opt.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("trace: otlp export failed: %s", resp.Status)
+	}
+	return nil
+}
+
+// NewOTLPExporter ...
+func NewOTLPExporter(opts ...*OTLPOption) *OTLPExporter {
+	e := &OTLPExporter{flush: make(chan struct{}, 1), done: make(chan struct{}), closed: make(chan struct{})}
+	if len(opts) > 0 && opts[0] != nil {
+		e.opt = *opts[0]
+	}
+	if len(e.opt.Endpoint) == 0 {
+		e.opt.Endpoint = "http://localhost:4318/v1/traces"
+	}
+	if e.opt.BatchSize <= 0 {
+		e.opt.BatchSize = 512
+	}
+	if e.opt.Interval <= 0 {
+		e.opt.Interval = 5 * time.Second
+	}
+	if e.opt.Client == nil {
+		e.opt.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	go e.loop()
+	return e
+}
+
+// CollectorHandler a stand-in of the OTLP/HTTP JSON collector, it receives the spans at /v1/traces
+// and writes them to the exporter, e.g.
+//
+//	http.ListenAndServe(":4318", trace.CollectorHandler(trace.NewStdoutExporter()))
+func CollectorHandler(exporter Exporter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if strings.Contains(r.Header.Get("Content-Type"), "protobuf") {
+			http.Error(w, "only OTLP/HTTP JSON is supported", http.StatusUnsupportedMediaType)
+			return
+		}
+		var req otlpRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for _, span := range otlpDecode(&req) {
+			if err := exporter.Export(span); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte("{}"))
+	})
+}
+
+// otlpEncode groups the spans by service
+func otlpEncode(spans []*Span) *otlpRequest {
+	req := new(otlpRequest)
+	index := make(map[string]int)
+	for _, span := range spans {
+		r := span.record()
+		i, ok := index[r.Service]
+		if !ok {
+			i = len(req.ResourceSpans)
+			index[r.Service] = i
+			var res otlpResource
+			if len(r.Service) > 0 {
+				res.Attributes = []otlpKeyValue{otlpAttr("service.name", r.Service)}
+			}
+			req.ResourceSpans = append(req.ResourceSpans, otlpResourceSpans{
+				Resource:   res,
+				ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: otlpScopeName}}},
+			})
+		}
+		s := otlpSpan{
+			TraceID:           r.TraceID,
+			SpanID:            r.SpanID,
+			ParentSpanID:      r.ParentID,
+			Name:              r.Name,
+			Kind:              otlpKind(span.Kind),
+			StartTimeUnixNano: strconv.FormatInt(r.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(r.Start.Add(r.Duration).UnixNano(), 10),
+		}
+		for key, val := range r.Attributes {
+			s.Attributes = append(s.Attributes, otlpAttr(key, val))
+		}
+		if len(r.Error) > 0 {
+			s.Status = &otlpStatus{Code: 2, Message: r.Error}
+		}
+		scope := &req.ResourceSpans[i].ScopeSpans[0]
+		scope.Spans = append(scope.Spans, s)
+	}
+	return req
+}
+
+// otlpDecode the spans of the request, the invalid ones are skipped
+func otlpDecode(req *otlpRequest) []*Span {
+	var spans []*Span
+	for _, rs := range req.ResourceSpans {
+		var service string
+		for _, attr := range rs.Resource.Attributes {
+			if attr.Key == "service.name" && attr.Value.StringValue != nil {
+				service = *attr.Value.StringValue
+			}
+		}
+		for _, ss := range rs.ScopeSpans {
+			for _, s := range ss.Spans {
+				span := &Span{service: service, Name: s.Name, ended: true}
+				if hex.DecodedLen(len(s.TraceID)) != len(span.Context.TraceID) ||
+					hex.DecodedLen(len(s.SpanID)) != len(span.Context.SpanID) {
+					continue
+				}
+				_, _ = hex.Decode(span.Context.TraceID[:], []byte(s.TraceID))
+				_, _ = hex.Decode(span.Context.SpanID[:], []byte(s.SpanID))
+				if len(s.ParentSpanID) == hex.EncodedLen(len(span.ParentID)) {
+					_, _ = hex.Decode(span.ParentID[:], []byte(s.ParentSpanID))
+				}
+				span.Context.Sampled = true
+				switch s.Kind {
+				case 2:
+					span.Kind = KindServer
+				case 3:
+					span.Kind = KindClient
+				default:
+					span.Kind = KindInternal
+				}
+				start, _ := strconv.ParseInt(s.StartTimeUnixNano, 10, 64)
+				end, _ := strconv.ParseInt(s.EndTimeUnixNano, 10, 64)
+				span.Start, span.End = time.Unix(0, start), time.Unix(0, end)
+				for _, attr := range s.Attributes {
+					span.SetAttr(attr.Key, attr.Value.value())
+				}
+				if s.Status != nil && s.Status.Code == 2 {
+					span.Error = s.Status.Message
+					if len(span.Error) == 0 {
+						span.Error = "error"
+					}
+				}
+				spans = append(spans, span)
+			}
+		}
+	}
+	return spans
+}
+
+// otlpKind the SpanKind of OTLP: 1 internal, 2 server, 3 client
+func otlpKind(kind SpanKind) int {
+	switch kind {
+	case KindServer:
+		return 2
+	case KindClient:
+		return 3
+	default:
+		return 1
+	}
+}
+
+// otlpAttr ...
+func otlpAttr(key string, val interface{}) otlpKeyValue {
+	kv := otlpKeyValue{Key: key}
+	switch v := val.(type) {
+	case string:
+		kv.Value.StringValue = &v
+	case bool:
+		kv.Value.BoolValue = &v
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		s := fmt.Sprint(v)
+		kv.Value.IntValue = &s
+	case float32:
+		f := float64(v)
+		kv.Value.DoubleValue = &f
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			s := fmt.Sprint(v)
+			kv.Value.StringValue = &s
+			break
+		}
+		kv.Value.DoubleValue = &v
+	default:
+		s := fmt.Sprint(v)
+		kv.Value.StringValue = &s
+	}
+	return kv
+}
+
+// value ...
+func (v otlpAnyValue) value() interface{} {
+	switch {
+	case v.StringValue != nil:
+		return *v.StringValue
+	case v.BoolValue != nil:
+		return *v.BoolValue
+	case v.IntValue != nil:
+		n, _ := strconv.ParseInt(*v.IntValue, 10, 64)
+		return n
+	case v.DoubleValue != nil:
+		return *v.DoubleValue
+	}
+	return nil
+}
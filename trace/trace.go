@@ -0,0 +1,255 @@
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	mrand "math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TraceparentHeader the W3C trace context header
+const TraceparentHeader = "traceparent"
+
+// SpanKind ...
+type SpanKind int
+
+const (
+	KindInternal SpanKind = iota + 1
+	KindServer
+	KindClient
+)
+
+func (k SpanKind) String() string {
+	switch k {
+	case KindServer:
+		return "server"
+	case KindClient:
+		return "client"
+	default:
+		return "internal"
+	}
+}
+
+type (
+	// TraceID ...
+	TraceID [16]byte
+	// SpanID ...
+	SpanID [8]byte
+
+	// SpanContext the ids of a span which are propagated by traceparent
+	SpanContext struct {
+		TraceID TraceID
+		SpanID  SpanID
+		Sampled bool
+	}
+
+	// Span an operation of a trace, it's exported when it ends
+	Span struct {
+		mu       sync.Mutex
+		tracer   *Tracer
+		ended    bool
+		service  string
+		Name     string
+		Kind     SpanKind
+		Context  SpanContext
+		ParentID SpanID
+		Start    time.Time
+		End      time.Time
+		//Attributes the values are string, bool, int64 or float64
+		Attributes map[string]interface{}
+		//Error the error message, empty if the operation succeeded
+		Error string
+	}
+
+	// Option ...
+	Option struct {
+		//ServiceName the service.name of the spans
+		ServiceName string
+		//SampleRate ratio of the new traces which are exported, default is 1 (all), negative exports none.
+		//A trace continued from traceparent follows its sampled flag.
+		SampleRate float64
+	}
+
+	// Tracer creates the spans and exports them
+	Tracer struct {
+		opt      Option
+		exporter Exporter
+	}
+
+	spanKey struct{}
+)
+
+// IsValid ...
+func (t TraceID) IsValid() bool { return t != TraceID{} }
+
+func (t TraceID) String() string { return hex.EncodeToString(t[:]) }
+
+// IsValid ...
+func (s SpanID) IsValid() bool { return s != SpanID{} }
+
+func (s SpanID) String() string { return hex.EncodeToString(s[:]) }
+
+// IsValid ...
+func (c SpanContext) IsValid() bool { return c.TraceID.IsValid() && c.SpanID.IsValid() }
+
+// Traceparent the value of the traceparent header, version 00
+func (c SpanContext) Traceparent() string {
+	flags := "00"
+	if c.Sampled {
+		flags = "01"
+	}
+	return "00-" + c.TraceID.String() + "-" + c.SpanID.String() + "-" + flags
+}
+
+// ParseTraceparent parses the traceparent header
+func ParseTraceparent(value string) (c SpanContext, err error) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return c, fmt.Errorf("invalid traceparent: %q", value)
+	}
+	if err = decodeHex(c.TraceID[:], parts[1]); err != nil {
+		return
+	}
+	if err = decodeHex(c.SpanID[:], parts[2]); err != nil {
+		return
+	}
+	var flags [1]byte
+	if err = decodeHex(flags[:], parts[3]); err != nil {
+		return
+	}
+	if !c.IsValid() {
+		return c, errors.New("invalid traceparent: zero id")
+	}
+	c.Sampled = flags[0]&1 == 1
+	return
+}
+
+// SetAttr ...
+func (s *Span) SetAttr(key string, val interface{}) *Span {
+	if s == nil {
+		return s
+	}
+	s.mu.Lock()
+	if s.Attributes == nil {
+		s.Attributes = make(map[string]interface{})
+	}
+	s.Attributes[key] = val
+	s.mu.Unlock()
+	return s
+}
+
+// SetError marks the span as failed, nil is ignored
+func (s *Span) SetError(err error) *Span {
+	if s == nil || err == nil {
+		return s
+	}
+	s.mu.Lock()
+	s.Error = err.Error()
+	s.mu.Unlock()
+	return s
+}
+
+// Finish ends the span and exports it if it's sampled, the later calls are ignored
+func (s *Span) Finish() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended, s.End = true, time.Now()
+	s.mu.Unlock()
+	if s.Context.Sampled && s.tracer.exporter != nil {
+		_ = s.tracer.exporter.Export(s)
+	}
+}
+
+// Exporter returns the exporter
+func (t *Tracer) Exporter() Exporter { return t.exporter }
+
+// ServiceName ...
+func (t *Tracer) ServiceName() string { return t.opt.ServiceName }
+
+// Start starts a span, it's the child of the span of ctx, or of the remote parent set by Extract
+func (t *Tracer) Start(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	span := &Span{tracer: t, service: t.opt.ServiceName, Name: name, Kind: kind, Start: time.Now()}
+	if parent := SpanContextFromContext(ctx); parent.IsValid() {
+		span.Context.TraceID, span.Context.Sampled, span.ParentID = parent.TraceID, parent.Sampled, parent.SpanID
+	} else {
+		_, _ = rand.Read(span.Context.TraceID[:])
+		span.Context.Sampled = t.opt.SampleRate >= 1 || (t.opt.SampleRate > 0 && mrand.Float64() < t.opt.SampleRate)
+	}
+	_, _ = rand.Read(span.Context.SpanID[:])
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// Extract returns ctx with the remote parent of the traceparent header, ctx if there is none
+func Extract(ctx context.Context, header http.Header) context.Context {
+	sc, err := ParseTraceparent(header.Get(TraceparentHeader))
+	if err != nil {
+		return ctx
+	}
+	return context.WithValue(ctx, spanKey{}, &Span{Context: sc, ended: true})
+}
+
+// Inject sets the traceparent header of the span of ctx
+func Inject(ctx context.Context, header http.Header) {
+	if sc := SpanContextFromContext(ctx); sc.IsValid() {
+		header.Set(TraceparentHeader, sc.Traceparent())
+	}
+}
+
+// SpanFromContext the span of ctx, nil if there is none. A remote parent isn't returned.
+func SpanFromContext(ctx context.Context) *Span {
+	if ctx == nil {
+		return nil
+	}
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	if span == nil || span.tracer == nil {
+		return nil
+	}
+	return span
+}
+
+// SpanContextFromContext the ids of the span of ctx, or of the remote parent
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	if ctx == nil {
+		return SpanContext{}
+	}
+	if span, _ := ctx.Value(spanKey{}).(*Span); span != nil {
+		return span.Context
+	}
+	return SpanContext{}
+}
+
+// New ...
+func New(exporter Exporter, opts ...*Option) *Tracer {
+	t := &Tracer{exporter: exporter}
+	if len(opts) > 0 && opts[0] != nil {
+		t.opt = *opts[0]
+	}
+	if t.opt.SampleRate == 0 {
+		t.opt.SampleRate = 1
+	}
+	return t
+}
+
+// decodeHex decodes the lowercase hex string into dst
+func decodeHex(dst []byte, s string) error {
+	if len(s) != hex.EncodedLen(len(dst)) || strings.ToLower(s) != s {
+		return fmt.Errorf("invalid traceparent field: %q", s)
+	}
+	_, err := hex.Decode(dst, []byte(s))
+	return err
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"io"
+	"strings"
 
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
@@ -123,6 +124,74 @@ func NewEncoder(w io.Writer) *Encoder {
 	return json.NewEncoder(w)
 }
 
+// ProjectJSON keeps only the paths of data, which are dot separated keys like "id" or "user.name".
+// The paths are applied to every element of an array, so "items.id" projects a list of objects,
+// and the keys keep the order of data. A path not found is skipped.
+func ProjectJSON(data []byte, paths ...string) []byte {
+	root := make(projection)
+	for _, path := range paths {
+		if path = strings.TrimSpace(path); len(path) == 0 {
+			continue
+		}
+		node := root
+		for _, key := range strings.Split(path, ".") {
+			if len(key) == 0 {
+				continue
+			}
+			if node[key] == nil {
+				node[key] = make(projection)
+			}
+			node = node[key]
+		}
+		// selects the whole value, it may have been a parent of other paths
+		node[""] = nil
+	}
+	if len(root) == 0 {
+		return data
+	}
+	return root.project(gjson.ParseBytes(data), nil)
+}
+
+// projection the tree of the paths of ProjectJSON, the empty key selects the whole value
+type projection map[string]projection
+
+// project ...
+func (p projection) project(val gjson.Result, dst []byte) []byte {
+	if _, ok := p[""]; ok || (!val.IsArray() && !val.IsObject()) {
+		return append(dst, val.Raw...)
+	}
+	if val.IsArray() {
+		dst = append(dst, '[')
+		var n int
+		val.ForEach(func(_, elem gjson.Result) bool {
+			if n > 0 {
+				dst = append(dst, ',')
+			}
+			n++
+			dst = p.project(elem, dst)
+			return true
+		})
+		return append(dst, ']')
+	}
+	dst = append(dst, '{')
+	var n int
+	val.ForEach(func(key, field gjson.Result) bool {
+		child, ok := p[key.String()]
+		if !ok {
+			return true
+		}
+		if n > 0 {
+			dst = append(dst, ',')
+		}
+		n++
+		dst = append(dst, key.Raw...)
+		dst = append(dst, ':')
+		dst = child.project(field, dst)
+		return true
+	})
+	return append(dst, '}')
+}
+
 func SetJson(data []byte, path string, val interface{}) []byte {
 	bts, _ := sjson.SetBytes(data, path, val)
 	return bts
@@ -2,8 +2,10 @@ package util
 
 import (
 	"errors"
+	"reflect"
 	"regexp"
 	"strings"
+	"sync"
 
 	lzh "github.com/go-playground/locales/zh"
 	ut "github.com/go-playground/universal-translator"
@@ -55,6 +57,24 @@ func (v *Validator) TranslateZh(es error) (err error) {
 	return
 }
 
+// ValidFieldsZh validates the struct, fields is the translated error of each invalid field,
+// key is the path of the json names under the struct, e.g. addr.city; err is the joined errors like ValidAndTranslateZh
+func (v *Validator) ValidFieldsZh(s interface{}) (fields map[string]string, err error) {
+	es := v.valid.Struct(s)
+	if e := (validator.ValidationErrors{}); errors.As(es, &e) {
+		fields = make(map[string]string, len(e))
+		for _, fieldError := range e {
+			key := fieldError.Namespace()
+			if i := strings.IndexByte(key, '.'); i >= 0 {
+				key = key[i+1:]
+			}
+			fields[key] = fieldError.Translate(v.trans)
+		}
+	}
+	err = v.TranslateZh(es)
+	return
+}
+
 // Valid ...
 func (v *Validator) Valid() *validator.Validate { return v.valid }
 
@@ -87,6 +107,8 @@ func (v *Validator) initialize() (err error) {
 	if err != nil {
 		return
 	}
+	//字段名使用json tag, 与请求的字段一致
+	validate.RegisterTagNameFunc(jsonFieldName)
 	v.trans = trans
 	v.valid = validate
 	customValidations := []func() error{
@@ -190,6 +212,50 @@ func (v *Validator) validatorRegValidation(tagName, text string, fn validator.Fu
 	})
 }
 
+// jsonFieldName the name of the field in json, the field name if it has no json tag
+func jsonFieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return field.Name
+	}
+	return name
+}
+
+var validateTagCache sync.Map
+
+// HasValidateTag reports whether the struct type, or a nested struct, has fields with the validate tag
+func HasValidateTag(typ reflect.Type) bool {
+	if v, ok := validateTagCache.Load(typ); ok {
+		return v.(bool)
+	}
+	has := hasValidateTag(typ, make(map[reflect.Type]bool))
+	validateTagCache.Store(typ, has)
+	return has
+}
+
+func hasValidateTag(typ reflect.Type, visited map[reflect.Type]bool) bool {
+	for typ.Kind() == reflect.Ptr || typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array || typ.Kind() == reflect.Map {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct || visited[typ] {
+		return false
+	}
+	visited[typ] = true
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if _, ok := field.Tag.Lookup("validate"); ok {
+			return true
+		}
+		if hasValidateTag(field.Type, visited) {
+			return true
+		}
+	}
+	return false
+}
+
 type listError []string
 
 func (l listError) Error() string { return strings.Join(l, ",") }